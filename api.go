@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// GenerateToBuffer runs generation for opts without touching the output
+// directory and returns the content of every generated file keyed by its
+// path relative to OutputDir, or by OutputFile when that is set. The Diff,
+// Clean, Incremental, Force, MetricsJSON and InitModule settings only affect
+// how the CLI writes the files and are ignored.
+func GenerateToBuffer(opts Options) (map[string][]byte, error) {
+	opts = opts.withDefaults()
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	genOpts, err := opts.generateOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := generateToBuffer(opts, genOpts, &runMetrics{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := make(map[string][]byte, len(result.Files))
+	for _, file := range result.Files {
+		buffer[file.Path] = file.Content
+	}
+	return buffer, nil
+}
+
+// generation is what generateToBuffer produced: the schemas it loaded, the
+// columns -skipBadColumns removed from them, and the generated files in
+// output order. Partial is set when the run timed out while loading, so
+// some tables are missing.
+type generation struct {
+	Schemas []keyspaceSchema
+	Skipped []string
+	Files   []generatedFile
+	Partial bool
+}
+
+// generateToBuffer is GenerateToBuffer for opts that already carry their
+// defaults and were validated. The CLI writes what it returns; it passes its
+// metrics and timeout guard, which GenerateToBuffer has no use for.
+func generateToBuffer(opts Options, genOpts generateOptions, metrics *runMetrics, guard *runGuard) (generation, error) {
+	session, schemas, skipped, err := loadSchemas(opts, metrics, guard)
+	if err != nil {
+		return generation{}, err
+	}
+
+	// The self-test is the last use of the session.
+	var failures int
+	if opts.SelfTest {
+		failures = runSelfTest(session, schemas, genOpts)
+	}
+	if session != nil {
+		session.Close()
+	}
+	if failures > 0 {
+		return generation{}, fmt.Errorf("%d columns failed the self-test; their generated types would not scan", failures)
+	}
+
+	partial := guard.timedOut()
+	genOpts.Partial = partial
+
+	generateStart := time.Now()
+	files, err := generateFiles(opts, genOpts, schemas)
+	if err != nil {
+		return generation{}, err
+	}
+	metrics.record("generate", generateStart, len(files), "files")
+
+	return generation{Schemas: schemas, Skipped: skipped, Files: files, Partial: partial}, nil
+}
+
+// loadSchemas reads the selected schemas, from opts.Schema or the cluster, checks
+// them against -maxTables and applies -enums, -columnOrder and
+// -skipBadColumns to them. It returns the session when one was opened, for
+// the caller to close, and the columns -skipBadColumns removed.
+func loadSchemas(opts Options, metrics *runMetrics, guard *runGuard) (*gocql.Session, []keyspaceSchema, []string, error) {
+	var session *gocql.Session
+	var schemas []keyspaceSchema
+
+	if opts.Schema != nil {
+		readStart := time.Now()
+		var err error
+		schemas, err = readSchemaJSON(opts.Schema, opts.Selection)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("reading the schema document: %w", err)
+		}
+		metrics.record("schema read", readStart, len(schemas), "keyspaces")
+	} else {
+		var err error
+		session, schemas, err = introspect(opts.Connection, opts.Selection, metrics, guard)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	if err := checkMaxTables(schemas, opts.MaxTables); err != nil {
+		if session != nil {
+			session.Close()
+		}
+		return nil, nil, nil, err
+	}
+
+	if enumColumns := splitList(opts.Enums); len(enumColumns) > 0 {
+		enumStart := time.Now()
+		for i := range schemas {
+			fetchEnums(session, &schemas[i], enumColumns, opts.EnumLimit)
+		}
+		metrics.record("enum fetch", enumStart, len(enumColumns), "columns")
+	}
+
+	if err := applyColumnOrders(schemas, opts.ColumnOrders); err != nil {
+		if session != nil {
+			session.Close()
+		}
+		return nil, nil, nil, err
+	}
+
+	var skipped []string
+	if opts.SkipBadColumns {
+		skipped = skipBadColumns(schemas, opts.IntType)
+	}
+
+	return session, schemas, skipped, nil
+}
+
+// generateFiles runs every selected emitter and the post-processing hook,
+// then applies -lineEnding. A single format writes straight into the output
+// directory; several formats each get their own subdirectory. Paths are
+// relative to OutputDir, except that -outputFile names the one file directly.
+func generateFiles(opts Options, genOpts generateOptions, schemas []keyspaceSchema) ([]generatedFile, error) {
+	formats, err := parseFormats(opts.Formats)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []generatedFile
+	for _, name := range formats {
+		emitted, err := formatEmitters[name](schemas, genOpts)
+		if err != nil {
+			return nil, fmt.Errorf("generating %s output: %w", name, err)
+		}
+
+		for _, file := range emitted {
+			if len(formats) > 1 {
+				file.Path = name + "/" + file.Path
+			}
+			files = append(files, file)
+		}
+	}
+
+	if opts.OutputFile != "" {
+		if len(files) != 1 {
+			return nil, fmt.Errorf("-outputFile needs output that fits in one file, but %d files were generated (select one keyspace, one format and no -splitFiles)", len(files))
+		}
+		files[0].Path = opts.OutputFile
+	}
+
+	if err := opts.postProcess(files); err != nil {
+		return nil, err
+	}
+
+	for i := range files {
+		files[i].Content = convertLineEndings(files[i].Content, opts.LineEnding)
+	}
+	return files, nil
+}
+
+// convertLineEndings rewrites every line ending in content as LF, or as CRLF
+// when lineEnding is "crlf".
+func convertLineEndings(content []byte, lineEnding string) []byte {
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	if lineEnding == "crlf" {
+		content = bytes.ReplaceAll(content, []byte("\n"), []byte("\r\n"))
+	}
+	return content
+}
+
+// checkMaxTables guards against pointing the tool at the wrong keyspace: it
+// fails when any keyspace has more than maxTables tables, unless maxTables is
+// negative.
+func checkMaxTables(schemas []keyspaceSchema, maxTables int) error {
+	if maxTables < 0 {
+		return nil
+	}
+	for _, schema := range schemas {
+		if len(schema.Tables) > maxTables {
+			return fmt.Errorf("keyspace %s has %d tables, more than -maxTables %d; narrow the selection with -table, -excludeTables or an ignore file, or raise -maxTables (0 disables the limit)", schema.Name, len(schema.Tables), maxTables)
+		}
+	}
+	return nil
+}
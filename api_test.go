@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fixtureSchema returns a reader of testdata/schema.json for Options.Schema.
+func fixtureSchema(t *testing.T) io.Reader {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join("testdata", "schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(content)
+}
+
+func TestGenerateToBuffer(t *testing.T) {
+	t.Parallel()
+
+	outputDir := t.TempDir()
+	files, err := GenerateToBuffer(Options{
+		Selection:  SchemaFlags{Keyspaces: "shop"},
+		Schema:     fixtureSchema(t),
+		OutputDir:  outputDir,
+		SplitFiles: true,
+		Formats:    "go,ts",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for path := range files {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	want := []string{"go/shop/doc.go", "go/shop/orders.go", "go/shop/page_views.go", "go/shop/types.go", "ts/shop/models.ts"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("GenerateToBuffer returned %v, want %v", paths, want)
+	}
+	if !strings.Contains(string(files["go/shop/orders.go"]), "type Orders struct {") {
+		t.Errorf("go/shop/orders.go lacks the Orders struct:\n%s", files["go/shop/orders.go"])
+	}
+
+	// Nothing is written, not even the hash cache.
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("GenerateToBuffer wrote to the output directory: %v", entries)
+	}
+}
+
+func TestGenerateToBufferOutputFile(t *testing.T) {
+	t.Parallel()
+
+	files, err := GenerateToBuffer(Options{
+		Selection:  SchemaFlags{Keyspaces: "shop"},
+		Schema:     fixtureSchema(t),
+		OutputFile: "internal/db/models.go",
+		LineEnding: "crlf",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, ok := files["internal/db/models.go"]
+	if len(files) != 1 || !ok {
+		t.Fatalf("GenerateToBuffer returned %d files, want only internal/db/models.go", len(files))
+	}
+	if !strings.Contains(string(content), "package models\r\n") {
+		t.Errorf("internal/db/models.go does not use CRLF line endings:\n%s", content)
+	}
+}
+
+func TestGenerateToBufferErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"invalid option", Options{Selection: SchemaFlags{Keyspaces: "shop"}, JSONCase: "kebab"}, `unknown json case "kebab"`},
+		{"unknown keyspace", Options{Selection: SchemaFlags{Keyspaces: "missing"}}, "keyspace missing is not in the schema"},
+		{"output file too small", Options{Selection: SchemaFlags{Keyspaces: "shop"}, SplitFiles: true, OutputFile: "models.go"}, "-outputFile needs output that fits in one file"},
+	}
+
+	for _, tt := range tests {
+		tt.opts.Schema = fixtureSchema(t)
+		_, err := GenerateToBuffer(tt.opts)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: GenerateToBuffer returned %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestGenerateToBufferPostProcess(t *testing.T) {
+	t.Parallel()
+
+	var seen []string
+	opts := Options{
+		Selection:  SchemaFlags{Keyspaces: "shop"},
+		Schema:     fixtureSchema(t),
+		SplitFiles: true,
+		LineEnding: "crlf",
+		PostProcess: func(path string, content []byte) ([]byte, error) {
+			seen = append(seen, path)
+			return append([]byte("// Reviewed.\n"), content...), nil
+		},
+	}
+
+	files, err := GenerateToBuffer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The hook sees every file in output order, and its output still gets
+	// the line ending conversion.
+	if want := []string{"shop/types.go", "shop/orders.go", "shop/page_views.go", "shop/doc.go"}; !slices.Equal(seen, want) {
+		t.Errorf("the hook saw %v, want %v", seen, want)
+	}
+	for path, content := range files {
+		if !strings.HasPrefix(string(content), "// Reviewed.\r\n") {
+			t.Errorf("%s does not start with the hook's line in CRLF:\n%s", path, content)
+		}
+	}
+
+	opts.Schema = fixtureSchema(t)
+	opts.PostProcess = func(path string, content []byte) ([]byte, error) {
+		return nil, errors.New("license header missing")
+	}
+	if _, err := GenerateToBuffer(opts); err == nil || !strings.Contains(err.Error(), "post-processing shop/types.go: license header missing") {
+		t.Errorf("a failing hook gave %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// emitAvro writes an Avro record schema per table. Columns outside the
+// primary key may be null in CQL, so they become ["null", T] unions that
+// default to null.
+func emitAvro(schemas []keyspaceSchema, opts generateOptions) ([]generatedFile, error) {
+	var files []generatedFile
+
+	for _, schema := range schemas {
+		mapper := typeMapper{}.withUserTypes(schema.Types, "")
+		udts := make(map[string]udtSchema, len(schema.Types))
+		for _, udt := range schema.Types {
+			udts[udt.Name] = udt
+		}
+
+		for _, table := range schema.Tables {
+			// Avro named types are defined once per document and referenced
+			// by name afterwards.
+			defined := make(map[string]bool)
+			fields := []interface{}{}
+
+			for _, col := range table.Columns {
+				avroType, err := cqlToAvro(col.Type, avroName(col.Name), mapper, udts, defined)
+				if err != nil {
+					return nil, fmt.Errorf("table %s column %s: %w", table.Name, col.Name, err)
+				}
+
+				field := map[string]interface{}{"name": avroName(col.Name), "type": avroType}
+				if !isPrimaryKey(col) {
+					field["type"] = []interface{}{"null", avroType}
+					field["default"] = nil
+				}
+				fields = append(fields, field)
+			}
+
+			record := map[string]interface{}{
+				"type":      "record",
+				"name":      toPascal(table.Name),
+				"namespace": avroName(schema.Name),
+				"doc":       generatedMarker,
+				"fields":    fields,
+			}
+
+			content, err := marshalJSON(record)
+			if err != nil {
+				return nil, err
+			}
+
+			filePath := sanitizePathComponent(schema.Name) + "/" + sanitizePathComponent(table.Name) + ".avsc"
+			files = append(files, generatedFile{Path: filePath, Content: content})
+		}
+	}
+
+	return files, nil
+}
+
+var avroInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// avroName makes a CQL identifier a valid Avro name, which must match
+// [A-Za-z_][A-Za-z0-9_]*.
+func avroName(name string) string {
+	name = avroInvalidChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// cqlToAvro maps a CQL type to an Avro schema. fieldName names the helper
+// records needed for maps whose keys are not strings.
+func cqlToAvro(cqlType string, fieldName string, mapper typeMapper, udts map[string]udtSchema, defined map[string]bool) (interface{}, error) {
+	node, err := mapper.parseCQLType(cqlType)
+	if err != nil {
+		return nil, err
+	}
+	return avroType(node, fieldName, mapper, udts, defined)
+}
+
+func avroType(node cqlTypeNode, fieldName string, mapper typeMapper, udts map[string]udtSchema, defined map[string]bool) (interface{}, error) {
+	switch {
+	case (node.Name == "list" || node.Name == "set") && len(node.Params) == 1:
+		items, err := avroType(node.Params[0], fieldName, mapper, udts, defined)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+
+	case node.Name == "map" && len(node.Params) == 2:
+		keys, err := avroType(node.Params[0], fieldName, mapper, udts, defined)
+		if err != nil {
+			return nil, err
+		}
+		values, err := avroType(node.Params[1], fieldName, mapper, udts, defined)
+		if err != nil {
+			return nil, err
+		}
+		if keys == "string" {
+			return map[string]interface{}{"type": "map", "values": values}, nil
+		}
+
+		// Avro map keys are always strings, so other key types become an
+		// array of key/value records.
+		entryName := toPascal(fieldName) + "Entry"
+		if defined[entryName] {
+			return map[string]interface{}{"type": "array", "items": entryName}, nil
+		}
+		defined[entryName] = true
+		entry := map[string]interface{}{
+			"type": "record",
+			"name": entryName,
+			"fields": []interface{}{
+				map[string]interface{}{"name": "key", "type": keys},
+				map[string]interface{}{"name": "value", "type": values},
+			},
+		}
+		return map[string]interface{}{"type": "array", "items": entry}, nil
+
+	case len(node.Params) > 0:
+		return nil, fmt.Errorf("unknown CQL type: %s", node.Name)
+	}
+
+	if udt, ok := udts[node.UDT]; ok {
+		name := mapper.UDTs[udt.Name]
+		if defined[name] {
+			return name, nil
+		}
+		defined[name] = true
+
+		fields := []interface{}{}
+		for _, field := range udt.Fields {
+			fieldType, err := cqlToAvro(field.Type, avroName(field.Name), mapper, udts, defined)
+			if err != nil {
+				return nil, fmt.Errorf("type %s field %s: %w", udt.Name, field.Name, err)
+			}
+			// UDT fields may always be null.
+			fields = append(fields, map[string]interface{}{
+				"name":    avroName(field.Name),
+				"type":    []interface{}{"null", fieldType},
+				"default": nil,
+			})
+		}
+		return map[string]interface{}{"type": "record", "name": name, "fields": fields}, nil
+	}
+
+	switch node.Name {
+	case "boolean":
+		return "boolean", nil
+	case "tinyint", "smallint", "int":
+		return "int", nil
+	case "bigint", "counter":
+		return "long", nil
+	case "float":
+		return "float", nil
+	case "double":
+		return "double", nil
+	case "text", "varchar", "ascii", "inet":
+		return "string", nil
+	case "varint", "decimal", "duration":
+		// Avro's decimal needs a fixed scale and its duration has millisecond
+		// precision, so these keep their CQL text form.
+		return "string", nil
+	case "uuid", "timeuuid":
+		return map[string]interface{}{"type": "string", "logicalType": "uuid"}, nil
+	case "timestamp":
+		return map[string]interface{}{"type": "long", "logicalType": "timestamp-millis"}, nil
+	case "date":
+		return map[string]interface{}{"type": "int", "logicalType": "date"}, nil
+	case "time":
+		return map[string]interface{}{"type": "long", "logicalType": "time-micros"}, nil
+	case "blob":
+		return "bytes", nil
+	default:
+		return nil, fmt.Errorf("unknown CQL type: %s", node.Name)
+	}
+}
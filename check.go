@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/gocql/gocql"
+)
+
+// protocolObserver records the native protocol version of the response
+// frames a session reads, which is the version the driver negotiated.
+type protocolObserver struct {
+	version atomic.Int32
+}
+
+func (o *protocolObserver) ObserveFrameHeader(ctx context.Context, header gocql.ObservedFrameHeader) {
+	// The high bit marks responses; the rest is the version.
+	if version := byte(header.Version); version&0x80 != 0 {
+		o.version.Store(int32(version & 0x7f))
+	}
+}
+
+// runCheck connects with the connection flags, reads system.local and
+// prints what it reached. No schema is read. Any failure exits non-zero.
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+
+	var conn ConnectionOptions
+	addConnectionFlags(fs, &conn)
+
+	fs.Parse(args)
+
+	if err := applyConnectionDefaults(fs, &conn); err != nil {
+		log.Fatal(err)
+	}
+
+	guard := startRunGuard(conn.Timeout, false)
+
+	cluster, err := newClusterConfig(conn, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	observer := &protocolObserver{}
+	cluster.FrameHeaderObserver = observer
+
+	session, err := cluster.CreateSession()
+	if err != nil {
+		log.Fatalf("could not connect to ScyllaDB: %v", err)
+	}
+	guard.track(session)
+	defer session.Close()
+
+	info, err := pingCluster(session)
+	if err != nil {
+		session.Close()
+		log.Fatalf("connected to %s:%d but could not query system.local: %v (check that the user may read system tables)", conn.Host, conn.Port, err)
+	}
+
+	fmt.Printf("Connected to %s:%d\n", conn.Host, conn.Port)
+	fmt.Printf("  Cluster:          %s\n", info.ClusterName)
+	fmt.Printf("  Datacenter:       %s\n", info.DataCenter)
+	fmt.Printf("  Release:          %s\n", info.ReleaseVersion)
+	fmt.Printf("  Protocol version: %d (server supports up to %s)\n", observer.version.Load(), info.ProtocolVersion)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultCqlshrcPath returns cqlsh's standard configuration path, or an empty
+// string when the home directory is unknown.
+func defaultCqlshrcPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cassandra", "cqlshrc")
+}
+
+// parseCqlshrc reads an INI-style cqlshrc file into its sections, keyed by
+// lowercased section and option names. Lines starting with ; or # are
+// comments, and options may be written as "key = value" or "key: value".
+func parseCqlshrc(filePath string) (map[string]map[string]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sections := make(map[string]map[string]string)
+	var section map[string]string
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			if sections[name] == nil {
+				sections[name] = make(map[string]string)
+			}
+			section = sections[name]
+			continue
+		}
+
+		separator := strings.IndexAny(line, "=:")
+		if separator < 0 || section == nil {
+			return nil, fmt.Errorf("%s:%d: expected a [section] or key = value line", filePath, lineNumber)
+		}
+
+		key := strings.ToLower(strings.TrimSpace(line[:separator]))
+		section[key] = strings.TrimSpace(line[separator+1:])
+	}
+
+	return sections, scanner.Err()
+}
+
+// explicitFlags returns the names of the flags set on the command line.
+func explicitFlags(fs *flag.FlagSet) map[string]bool {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	return explicit
+}
+
+// applyCqlshrc fills connection settings that were not given as flags from
+// the [connection] and [authentication] sections of a cqlshrc file. The file
+// named by -cqlshrc must exist; the default one is used only when present.
+func applyCqlshrc(fs *flag.FlagSet, opts *ConnectionOptions) error {
+	filePath, required := opts.Cqlshrc, opts.Cqlshrc != ""
+	if !required {
+		filePath = defaultCqlshrcPath()
+		if filePath == "" {
+			return nil
+		}
+	}
+
+	sections, err := parseCqlshrc(filePath)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	explicit := explicitFlags(fs)
+
+	connection := sections["connection"]
+	authentication := sections["authentication"]
+
+	if value, ok := connection["hostname"]; ok && !explicit["host"] {
+		opts.Host = value
+	}
+	if value, ok := connection["port"]; ok && !explicit["port"] {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid port %q", filePath, value)
+		}
+		opts.Port = port
+	}
+	if value, ok := authentication["username"]; ok && !explicit["username"] {
+		opts.Username = value
+	}
+	if value, ok := authentication["password"]; ok && !explicit["password"] {
+		opts.Password = value
+	}
+
+	return nil
+}
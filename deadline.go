@@ -0,0 +1,127 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// runGuard closes the session, removes unfinished temporary files and exits
+// when the run exceeds its deadline or the process is interrupted. log.Fatal
+// skips deferred calls, so the guard is what guarantees cleanup on those
+// paths.
+//
+// With partial set, a timeout instead closes the session and lets the run
+// continue, so the schema fetched so far can still be generated; timedOut
+// then reports true. Interrupts always exit.
+type runGuard struct {
+	mu        sync.Mutex
+	session   *gocql.Session
+	tempFiles map[string]bool
+	partial   bool
+	expired   bool
+}
+
+// startRunGuard arms the guard. A zero timeout only handles interrupts.
+func startRunGuard(timeout time.Duration, partial bool) *runGuard {
+	guard := &runGuard{tempFiles: make(map[string]bool), partial: partial}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+
+	go func() {
+		select {
+		case <-expired:
+			if !guard.partial {
+				guard.cleanup()
+				log.Fatalf("Timed out after %s (raise -timeout if the cluster is slow to respond)", timeout)
+			}
+			guard.expire()
+			log.Printf("Timed out after %s; generating partial output from the schema fetched so far", timeout)
+		case sig := <-interrupts:
+			guard.cleanup()
+			log.Fatalf("Interrupted by %s", sig)
+		}
+
+		// Only a partial timeout gets here, and interrupts still exit.
+		sig := <-interrupts
+		guard.cleanup()
+		log.Fatalf("Interrupted by %s", sig)
+	}()
+
+	return guard
+}
+
+// track registers the session to close if the guard fires. A nil guard
+// tracks nothing.
+func (g *runGuard) track(session *gocql.Session) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.session = session
+}
+
+// trackTempFile registers a temporary file to remove if the guard fires;
+// calling finish, once the file was renamed into place, unregisters it. A nil
+// guard tracks nothing.
+func (g *runGuard) trackTempFile(path string) (finish func()) {
+	if g == nil {
+		return func() {}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.tempFiles[path] = true
+
+	return func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		delete(g.tempFiles, path)
+	}
+}
+
+// expire marks the run as timed out and closes the session, which makes any
+// query still in flight fail.
+func (g *runGuard) expire() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.expired = true
+	if g.session != nil {
+		g.session.Close()
+	}
+}
+
+// timedOut reports whether a partial guard's timeout has passed.
+func (g *runGuard) timedOut() bool {
+	if g == nil {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.expired
+}
+
+func (g *runGuard) cleanup() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.session != nil {
+		g.session.Close()
+	}
+	for path := range g.tempFiles {
+		os.Remove(path)
+	}
+}
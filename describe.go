@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// emitDescribe writes one schema.cql summarizing every keyspace the way
+// cqlsh's DESCRIBE does, for reading rather than for generating code.
+func emitDescribe(schemas []keyspaceSchema, opts generateOptions) ([]generatedFile, error) {
+	content := "-- " + generatedMarker + "\n\n" + describeSchemas(schemas)
+	return []generatedFile{{Path: "schema.cql", Content: []byte(content)}}, nil
+}
+
+// describeSchemas renders the user-defined types and tables of each keyspace
+// as CREATE statements with their key layout. Only what introspection reads
+// is shown, so table options other than the comment are left out.
+func describeSchemas(schemas []keyspaceSchema) string {
+	var b strings.Builder
+
+	for i, schema := range schemas {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "-- Keyspace %s: %d tables\n", schema.Name, len(schema.Tables))
+
+		keyspace := cqlIdentifier(schema.Name)
+
+		for _, udt := range schema.Types {
+			fmt.Fprintf(&b, "\nCREATE TYPE %s.%s (\n", keyspace, cqlIdentifier(udt.Name))
+			for j, field := range udt.Fields {
+				separator := ","
+				if j == len(udt.Fields)-1 {
+					separator = ""
+				}
+				fmt.Fprintf(&b, "    %s %s%s\n", cqlIdentifier(field.Name), field.Type, separator)
+			}
+			b.WriteString(");\n")
+		}
+
+		for _, table := range schema.Tables {
+			b.WriteString("\n")
+			b.WriteString(describeTable(keyspace, table))
+		}
+	}
+
+	return b.String()
+}
+
+// describeTable renders one CREATE TABLE statement. Columns are listed in
+// the order they were fetched, key columns first.
+func describeTable(keyspace string, table tableSchema) string {
+	var b strings.Builder
+	var partitionKey, clustering, clusteringOrders []string
+
+	fmt.Fprintf(&b, "CREATE TABLE %s.%s (\n", keyspace, cqlIdentifier(table.Name))
+
+	for _, col := range table.Columns {
+		name := cqlIdentifier(col.Name)
+
+		static := ""
+		switch col.Kind {
+		case "partition_key":
+			partitionKey = append(partitionKey, name)
+		case "clustering":
+			clustering = append(clustering, name)
+			clusteringOrders = append(clusteringOrders, name+" "+clusteringOrder(col))
+		case "static":
+			static = " static"
+		}
+
+		fmt.Fprintf(&b, "    %s %s%s,\n", name, col.Type, static)
+	}
+
+	key := strings.Join(partitionKey, ", ")
+	if len(partitionKey) > 1 {
+		key = "(" + key + ")"
+	}
+	if len(clustering) > 0 {
+		key += ", " + strings.Join(clustering, ", ")
+	}
+	fmt.Fprintf(&b, "    PRIMARY KEY (%s)\n)", key)
+
+	var properties []string
+	if len(clusteringOrders) > 0 {
+		properties = append(properties, "CLUSTERING ORDER BY ("+strings.Join(clusteringOrders, ", ")+")")
+	}
+	if table.Comment != "" {
+		properties = append(properties, "comment = '"+strings.ReplaceAll(table.Comment, "'", "''")+"'")
+	}
+	if len(properties) > 0 {
+		b.WriteString(" WITH " + strings.Join(properties, "\n    AND "))
+	}
+	b.WriteString(";\n")
+
+	return b.String()
+}
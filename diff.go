@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const diffContext = 3
+
+type diffOp struct {
+	Kind byte
+	Line string
+}
+
+// unifiedDiff renders the changes from oldContent to newContent as a unified
+// diff with three lines of context. It returns an empty string when the
+// contents are identical.
+func unifiedDiff(oldName string, newName string, oldContent string, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+
+	ops := diffLines(splitLines(oldContent), splitLines(newContent))
+
+	diff := fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName)
+
+	// Line numbers (1-based) in the old and new file reached before each op.
+	oldLines := make([]int, len(ops)+1)
+	newLines := make([]int, len(ops)+1)
+	oldLines[0], newLines[0] = 1, 1
+	for i, op := range ops {
+		oldLines[i+1], newLines[i+1] = oldLines[i], newLines[i]
+		if op.Kind != '+' {
+			oldLines[i+1]++
+		}
+		if op.Kind != '-' {
+			newLines[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == ' ' {
+			i++
+			continue
+		}
+
+		start := max(i-diffContext, 0)
+		end := i
+		for end < len(ops) {
+			if ops[end].Kind != ' ' {
+				end++
+				continue
+			}
+
+			next := end
+			for next < len(ops) && ops[next].Kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContext {
+				end = min(end+diffContext, len(ops))
+				break
+			}
+			end = next
+		}
+
+		oldStart, oldCount := oldLines[start], oldLines[end]-oldLines[start]
+		newStart, newCount := newLines[start], newLines[end]-newLines[start]
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+
+		diff += fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, op := range ops[start:end] {
+			diff += string(op.Kind) + op.Line + "\n"
+		}
+
+		i = end
+	}
+
+	return diff
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// diffLines computes a line-level edit script using the longest common
+// subsequence of the two inputs. Common leading and trailing lines are
+// stripped first, which keeps the table small for typical regenerations.
+func diffLines(a []string, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(midA) && j < len(midB) {
+		switch {
+		case midA[i] == midB[j]:
+			ops = append(ops, diffOp{' ', midA[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', midA[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', midB[j]})
+			j++
+		}
+	}
+	for ; i < len(midA); i++ {
+		ops = append(ops, diffOp{'-', midA[i]})
+	}
+	for ; j < len(midB); j++ {
+		ops = append(ops, diffOp{'+', midB[j]})
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	return ops
+}
+
+// generatedFile is one output file. Table, set in -splitFiles mode, is the
+// keyspace.table whose declarations alone make up the file.
+type generatedFile struct {
+	Path    string
+	Content []byte
+	Table   string
+}
+
+// diffGeneratedFiles prints a unified diff between each generated file and
+// its current contents on disk, reporting whether any file differs.
+func diffGeneratedFiles(files []generatedFile) (bool, error) {
+	changed := false
+
+	for _, file := range files {
+		existing, err := os.ReadFile(file.Path)
+		oldName := file.Path
+		if errors.Is(err, os.ErrNotExist) {
+			oldName = "/dev/null"
+		} else if err != nil {
+			return changed, err
+		}
+
+		if diff := unifiedDiff(oldName, file.Path, string(existing), string(file.Content)); diff != "" {
+			fmt.Print(diff)
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/token"
+	"os"
+	"strings"
+)
+
+// loadFieldNames reads a -fieldNames file of "table.column FieldName" lines
+// and returns the field names keyed by table and then column. Blank lines and
+// lines starting with # are skipped. Each name must be an exported Go
+// identifier, and no two columns of a table may be given the same name.
+func loadFieldNames(filePath string) (map[string]map[string]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fieldNames := make(map[string]map[string]string)
+	lineNumber := 0
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected table.column FieldName, got %q", filePath, lineNumber, line)
+		}
+
+		tableName, columnName, ok := strings.Cut(parts[0], ".")
+		if !ok || tableName == "" || columnName == "" {
+			return nil, fmt.Errorf("%s:%d: %q is not a table.column entry", filePath, lineNumber, parts[0])
+		}
+
+		name := parts[1]
+		if !token.IsIdentifier(name) || !token.IsExported(name) {
+			return nil, fmt.Errorf("%s:%d: %q is not an exported Go identifier", filePath, lineNumber, name)
+		}
+
+		if fieldNames[tableName] == nil {
+			fieldNames[tableName] = make(map[string]string)
+		}
+		if _, ok := fieldNames[tableName][columnName]; ok {
+			return nil, fmt.Errorf("%s:%d: %s is listed more than once", filePath, lineNumber, parts[0])
+		}
+		for other, otherName := range fieldNames[tableName] {
+			if otherName == name {
+				return nil, fmt.Errorf("%s:%d: %s and %s.%s are both named %s", filePath, lineNumber, parts[0], tableName, other, name)
+			}
+		}
+		fieldNames[tableName][columnName] = name
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return fieldNames, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// formatEmitter renders the introspected schema in one output format. The
+// returned paths are relative to the format's output root.
+type formatEmitter func(schemas []keyspaceSchema, opts generateOptions) ([]generatedFile, error)
+
+var formatEmitters = map[string]formatEmitter{
+	"go":          emitGo,
+	"schema-json": emitSchemaJSON,
+	"ts":          emitTypeScript,
+	"jsonschema":  emitJSONSchema,
+	"mapscan":     emitMapScan,
+	"openapi":     emitOpenAPI,
+	"avro":        emitAvro,
+	"describe":    emitDescribe,
+}
+
+// formatNames lists the supported formats in the order shown in help text.
+var formatNames = []string{"go", "schema-json", "ts", "jsonschema", "mapscan", "openapi", "avro", "describe"}
+
+// parseFormats validates a comma-separated -format value, dropping duplicates.
+func parseFormats(value string) ([]string, error) {
+	var formats []string
+	seen := make(map[string]bool)
+
+	for _, name := range splitList(value) {
+		if _, ok := formatEmitters[name]; !ok {
+			return nil, fmt.Errorf("unknown format %q: expected one or more of %s", name, strings.Join(formatNames, ", "))
+		}
+		if !seen[name] {
+			seen[name] = true
+			formats = append(formats, name)
+		}
+	}
+
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no output format given")
+	}
+	return formats, nil
+}
+
+// emitGo writes the Go code of each keyspace into a directory named after
+// it, or into one package directory for all of them with PrefixKeyspace.
+// Keyspaces whose files would land on the same path are an error.
+func emitGo(schemas []keyspaceSchema, opts generateOptions) ([]generatedFile, error) {
+	var files []generatedFile
+	owners := make(map[string]string)
+	add := func(keyspace string, file generatedFile) error {
+		if previous, ok := owners[file.Path]; ok {
+			return fmt.Errorf("keyspaces %s and %s both generate %s", previous, keyspace, file.Path)
+		}
+		owners[file.Path] = keyspace
+		files = append(files, file)
+		return nil
+	}
+
+	for _, schema := range schemas {
+		dirName := sanitizePathComponent(schema.Name)
+
+		// With PrefixKeyspace the keyspaces share one package directory,
+		// named after the package, and each file name starts with its
+		// keyspace; the prefixed Go names keep their declarations apart.
+		packageDir := dirName
+		if opts.PrefixKeyspace {
+			packageDir = opts.Package
+		}
+
+		if opts.SplitFiles {
+			tableFiles, err := generateSplitFiles(schema, opts)
+			if err != nil {
+				return nil, fmt.Errorf("keyspace %s: %w", schema.Name, err)
+			}
+
+			for _, file := range tableFiles {
+				file.Path = packageDir + "/" + file.Path
+				if err := add(schema.Name, file); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		content, err := generateKeyspaceFile(schema, opts)
+		if err != nil {
+			return nil, fmt.Errorf("keyspace %s: %w", schema.Name, err)
+		}
+
+		// A single keyspace gets a file named after the package, which is
+		// main.go for -package main; several keyspaces, or keyspaces sharing
+		// a package, each get a file named after the keyspace.
+		filePath := dirName + "/" + opts.Package + ".go"
+		if len(schemas) > 1 || opts.PrefixKeyspace {
+			filePath = packageDir + "/" + dirName + ".go"
+		}
+
+		if err := add(schema.Name, generatedFile{Path: filePath, Content: content}); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// schemaDocument is the schema-json output. Comment is only set when the
+// document is written to a file, where it marks the file as generated.
+type schemaDocument struct {
+	Comment   string           `json:"$comment,omitempty"`
+	Keyspaces []keyspaceSchema `json:"keyspaces"`
+}
+
+// generatedMarker is the generated header without comment syntax, for
+// formats such as JSON that carry it in a field.
+var generatedMarker = strings.TrimPrefix(generatedHeader, "// ")
+
+func encodeSchemaJSON(schemas []keyspaceSchema, comment string) ([]byte, error) {
+	return marshalJSON(schemaDocument{Comment: comment, Keyspaces: schemas})
+}
+
+// marshalJSON encodes v as indented JSON without HTML escaping, so CQL types
+// such as map<text, int> stay readable.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func emitSchemaJSON(schemas []keyspaceSchema, opts generateOptions) ([]generatedFile, error) {
+	content, err := encodeSchemaJSON(schemas, generatedMarker)
+	if err != nil {
+		return nil, err
+	}
+	return []generatedFile{{Path: "schema.json", Content: content}}, nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEmitGoMixedCaseKeyspace(t *testing.T) {
+	schemas := []keyspaceSchema{{
+		Name: `Shop:EU`,
+		Tables: []tableSchema{{Name: "orders", Columns: []column{
+			{Name: "id", Type: "uuid", Kind: "partition_key"},
+		}}},
+	}}
+
+	files, err := emitGo(schemas, testGenerateOptions(func(opts *generateOptions) {
+		opts.Gocqlx = true
+		opts.Insert = true
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("emitGo wrote %d files, want 1", len(files))
+	}
+
+	// The directory drops characters that are illegal in paths but keeps the
+	// case; queries and table metadata quote the original name.
+	if want := "Shop_EU/models.go"; files[0].Path != want {
+		t.Errorf("file path is %s, want %s", files[0].Path, want)
+	}
+	source := string(files[0].Content)
+	for _, want := range []string{
+		`INSERT INTO \"Shop:EU\".orders (id) VALUES (?)`,
+		`Name:    "\"Shop:EU\".orders",`,
+	} {
+		if !strings.Contains(source, want) {
+			t.Errorf("generated file lacks %s:\n%s", want, source)
+		}
+	}
+}
+
+func TestEmitGoPrefixKeyspaceSharesPackage(t *testing.T) {
+	orders := tableSchema{Name: "orders", Columns: []column{{Name: "id", Type: "uuid", Kind: "partition_key"}}}
+	schemas := []keyspaceSchema{{Name: "shop", Tables: []tableSchema{orders}}, {Name: "shop_eu", Tables: []tableSchema{orders}}}
+
+	for splitFiles, want := range map[bool][]string{
+		false: {"models/shop.go", "models/shop_eu.go"},
+		true:  {"models/shop_orders.go", "models/shop_doc.go", "models/shop_eu_orders.go", "models/shop_eu_doc.go"},
+	} {
+		files, err := emitGo(schemas, testGenerateOptions(func(opts *generateOptions) {
+			opts.PrefixKeyspace = true
+			opts.SplitFiles = splitFiles
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var paths []string
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+		if !slices.Equal(paths, want) {
+			t.Errorf("splitFiles %v: emitGo wrote %v, want %v", splitFiles, paths, want)
+		}
+	}
+
+	// A table file of one keyspace can take the name of another's.
+	schemas[0].Tables = append(schemas[0].Tables, tableSchema{Name: "eu_orders", Columns: orders.Columns})
+	_, err := emitGo(schemas, testGenerateOptions(func(opts *generateOptions) {
+		opts.PrefixKeyspace = true
+		opts.SplitFiles = true
+	}))
+	if err == nil || !strings.Contains(err.Error(), "keyspaces shop and shop_eu both generate models/shop_eu_orders.go") {
+		t.Errorf("colliding file names gave %v", err)
+	}
+}
+
+// TestGeneratedGoCompiles generates Go from testdata/schema.json under
+// several option sets and vets the output against the gocql version this
+// module pins.
+func TestGeneratedGoCompiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go vet")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not on PATH")
+	}
+
+	variants := map[string]Options{
+		"defaults": {},
+		"everything": {
+			Pointers:             true,
+			OmitEmpty:            true,
+			ValidateTags:         true,
+			TypeComments:         true,
+			DocComments:          true,
+			FieldMap:             true,
+			Registry:             true,
+			RoutingKey:           true,
+			PartitionKeyStruct:   true,
+			IterWrapper:          true,
+			Insert:               true,
+			Methods:              true,
+			IndexMethods:         true,
+			BatchHelpers:         true,
+			Fingerprint:          true,
+			Accessors:            true,
+			Constructors:         true,
+			JSONEmptyCollections: true,
+			UDTMarshalers:        true,
+		},
+		"sql":          {NullType: "sql", StrictInt: true, OrderedMaps: true, SplitFiles: true},
+		"gocqlx":       {Gocqlx: true, Registry: true, Methods: true},
+		"gocqlx_split": {Gocqlx: true, Registry: true, SplitFiles: true},
+
+		// The prefixed variants put a second copy of the keyspace into the
+		// same package.
+		"prefixed": {
+			PrefixKeyspace: true,
+			OrderedMaps:    true,
+			Registry:       true,
+			RoutingKey:     true,
+			IterWrapper:    true,
+			Methods:        true,
+			IndexMethods:   true,
+			BatchHelpers:   true,
+			Fingerprint:    true,
+			Constructors:   true,
+			UDTMarshalers:  true,
+		},
+		"prefixed_split": {PrefixKeyspace: true, SplitFiles: true, Gocqlx: true, Registry: true, Methods: true},
+	}
+
+	dir := t.TempDir()
+	goMod, err := os.ReadFile("go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goMod = bytes.Replace(goMod, []byte("module github.com/ekremugur17/go-cql-scaffold"), []byte("module generated"), 1)
+
+	// gocqlx cannot be downloaded here, so the -gocqlx output is vetted
+	// against testdata/gocqlx, which declares what the helpers use with the
+	// signatures of gocqlx v2.
+	gocqlx, err := filepath.Abs(filepath.Join("testdata", "gocqlx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	goMod = append(goMod, fmt.Sprintf("\nrequire github.com/scylladb/gocqlx/v2 v2.0.0\n\nreplace github.com/scylladb/gocqlx/v2 => %s\n", gocqlx)...)
+	goSum, err := os.ReadFile("go.sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string][]byte{"go.mod": goMod, "go.sum": goSum} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, opts := range variants {
+		opts.Selection = SchemaFlags{Keyspaces: "shop"}
+
+		schemaFile, err := os.Open(filepath.Join("testdata", "schema.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		schemas, err := readSchemaJSON(schemaFile, opts.Selection)
+		schemaFile.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opts.PrefixKeyspace {
+			copied := schemas[0]
+			copied.Name = "shop_eu"
+			schemas = append(schemas, copied)
+		}
+
+		opts = opts.withDefaults()
+		if err := opts.Validate(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		genOpts, err := opts.generateOptions()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		files, err := generateFiles(opts, genOpts, schemas)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		for _, file := range files {
+			filePath := filepath.Join(dir, name, filepath.FromSlash(file.Path))
+			if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filePath, file.Content, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	vet := exec.Command(goTool, "vet", "./...")
+	vet.Dir = dir
+	vet.Env = append(os.Environ(), "GOFLAGS=-mod=readonly", "GOPROXY=off", "GOWORK=off")
+	if output, err := vet.CombinedOutput(); err != nil {
+		t.Fatalf("go vet of the generated code failed: %v\n%s", err, output)
+	}
+}
+
+func TestEmitOpenAPITypeAndTableOfOneName(t *testing.T) {
+	schemas := []keyspaceSchema{{
+		Name:  "shop",
+		Types: []udtSchema{{Name: "address", Fields: []udtField{{Name: "street", Type: "text"}}}},
+		Tables: []tableSchema{{Name: "address", Columns: []column{
+			{Name: "id", Type: "uuid", Kind: "partition_key"},
+			{Name: "home", Type: "frozen<address>"},
+		}}},
+	}}
+
+	files, err := emitOpenAPI(schemas, testGenerateOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := string(files[0].Content)
+	for _, want := range []string{`"Address": {`, `"AddressUDT": {`, `"$ref": "#/components/schemas/AddressUDT"`, `"street": {`} {
+		if !strings.Contains(source, want) {
+			t.Errorf("openapi.json lacks %s:\n%s", want, source)
+		}
+	}
+
+	// Two tables that differ only in case have nowhere else to go.
+	schemas[0].Tables = append(schemas[0].Tables, tableSchema{Name: "Address", Columns: schemas[0].Tables[0].Columns})
+	if _, err := emitOpenAPI(schemas, testGenerateOptions()); err == nil || !strings.Contains(err.Error(), "table address and table Address are both components.schemas Address") {
+		t.Errorf("colliding tables gave %v", err)
+	}
+}
@@ -0,0 +1,2019 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"math"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/iancoleman/strcase"
+)
+
+// structOptions controls how table columns are rendered as struct fields.
+//
+// Only columns outside the primary key are treated as nullable. NullType
+// picks how nullable scalar columns are typed: "none" keeps the plain type,
+// "pointers" makes them pointers and "sql" wraps them in database/sql null
+// types. PointerColumns overrides it per column: true makes the column a
+// pointer and false keeps its plain type. Blobs, inet addresses, lists, sets
+// and maps keep their plain type because a nil slice or map already
+// represents null.
+// With OmitEmpty, nullable columns of every type get ",omitempty" on their
+// json tag. Primary key columns are never pointers and never omitted.
+//
+// Embed names a shared struct that is embedded as the first field,
+// ValidateTags marks primary key fields with validate:"required" and
+// TypeComments annotates each field with its CQL type and Doc, when set, is
+// the struct's doc comment text. JSONCase picks how
+// json tag names are derived from column names, and NoJSON leaves json tags
+// out; db and cql tags always use the column name unchanged. ExtraTags adds
+// further tags after the json tag. FieldNames maps columns to Go field names
+// that replace the derived ones.
+type structOptions struct {
+	DBTags         bool
+	JSONCase       string
+	TypeComments   bool
+	NullType       string
+	PointerColumns map[string]bool
+	FieldNames     map[string]string
+	NoJSON         bool
+	ExtraTags      []extraTag
+	OmitEmpty      bool
+	ValidateTags   bool
+	Embed          string
+	Doc            string
+	Types          typeMapper
+}
+
+func generateGoStruct(structName string, columns []column, opts structOptions) (string, error) {
+	structDefinition := ""
+	if opts.Doc != "" {
+		structDefinition += wrapComment(opts.Doc, docCommentWidth)
+	}
+
+	var clustering []string
+	for _, col := range columns {
+		if col.Kind == "clustering" {
+			clustering = append(clustering, col.Name+" "+clusteringOrder(col))
+		}
+	}
+	if len(clustering) > 0 {
+		if opts.Doc != "" {
+			structDefinition += "//\n"
+		}
+		structDefinition += fmt.Sprintf("// %s rows are clustered by %s.\n", structName, strings.Join(clustering, ", "))
+	}
+
+	structDefinition += fmt.Sprintf("type %s struct {\n", structName)
+
+	if opts.Embed != "" {
+		structDefinition += fmt.Sprintf("    %s\n", opts.Embed)
+	}
+
+	names := uniqueFieldNames(columnNames(columns), opts.FieldNames)
+
+	for _, col := range columns {
+		goType, err := opts.fieldType(col)
+		if err != nil {
+			return "", err
+		}
+
+		nullable := !isPrimaryKey(col)
+
+		var tags []string
+		if opts.DBTags {
+			tags = append(tags, fmt.Sprintf("db:\"%s\"", col.Name))
+		}
+		if !opts.NoJSON {
+			jsonName := jsonTagName(col.Name, opts.JSONCase)
+			if nullable && opts.OmitEmpty {
+				jsonName += ",omitempty"
+			}
+			tags = append(tags, fmt.Sprintf("json:\"%s\"", jsonName))
+		}
+		for _, extra := range opts.ExtraTags {
+			tags = append(tags, fmt.Sprintf("%s:\"%s\"", extra.Key, jsonTagName(col.Name, extra.Case)))
+		}
+		if opts.ValidateTags && !nullable {
+			tags = append(tags, `validate:"required"`)
+		}
+
+		structDefinition += fmt.Sprintf("    %s %s", names[col.Name], goType)
+		if len(tags) > 0 {
+			structDefinition += " `" + strings.Join(tags, " ") + "`"
+		}
+		if opts.TypeComments {
+			structDefinition += " // " + fieldComment(col)
+		} else if isTimeOfDay(col) {
+			// time.Duration invites reading CQL time as an interval.
+			structDefinition += " // " + timeOfDayNote
+		}
+		structDefinition += "\n"
+	}
+
+	structDefinition += "}\n"
+	return structDefinition, nil
+}
+
+// fieldType returns the Go type of the struct field for col, applying the
+// null typing to nullable columns.
+func (opts structOptions) fieldType(col column) (string, error) {
+	goType, err := opts.Types.cqlToGoType(col.Type)
+	if err != nil {
+		return "", err
+	}
+
+	if !isPrimaryKey(col) && !isNilable(goType) {
+		nullType := opts.NullType
+		if pointer, ok := opts.PointerColumns[col.Name]; ok {
+			nullType = "none"
+			if pointer {
+				nullType = "pointers"
+			}
+		}
+		goType = nullableType(goType, nullType)
+	}
+
+	return goType, nil
+}
+
+// embedSpec describes a struct shared by every table that contains all of
+// its columns with the same types.
+type embedSpec struct {
+	Name    string
+	Columns []column
+}
+
+// resolveEmbed builds the shared struct from the first table that contains
+// every requested column. It reports false when no table qualifies.
+func resolveEmbed(name string, columnNames []string, tables []tableSchema) (embedSpec, bool) {
+	for _, table := range tables {
+		var columns []column
+		for _, columnName := range columnNames {
+			if col, ok := findColumn(table.Columns, columnName); ok {
+				columns = append(columns, col)
+			}
+		}
+
+		if len(columns) == len(columnNames) {
+			return embedSpec{Name: toPascal(name), Columns: columns}, true
+		}
+	}
+
+	return embedSpec{}, false
+}
+
+// matches reports whether a table carries every shared column with the same
+// type and nullability, so embedding does not change any field's type.
+func (spec embedSpec) matches(columns []column) bool {
+	for _, shared := range spec.Columns {
+		col, ok := findColumn(columns, shared.Name)
+		if !ok || col.Type != shared.Type || isPrimaryKey(col) != isPrimaryKey(shared) {
+			return false
+		}
+	}
+	return true
+}
+
+// strip returns the columns that are not covered by the shared struct.
+func (spec embedSpec) strip(columns []column) []column {
+	var remaining []column
+	for _, col := range columns {
+		if _, ok := findColumn(spec.Columns, col.Name); !ok {
+			remaining = append(remaining, col)
+		}
+	}
+	return remaining
+}
+
+func findColumn(columns []column, name string) (column, bool) {
+	for _, col := range columns {
+		if col.Name == name {
+			return col, true
+		}
+	}
+	return column{}, false
+}
+
+// fieldName returns the Go struct field name generated for a column.
+func fieldName(columnName string) string {
+	return exportedName(strcase.ToCamel(columnName))
+}
+
+// exportedName makes a camel-cased name an exported Go identifier, since
+// strcase leaves names such as "$x" lowercase and "_1" starting with a digit.
+// Names that do not start with a letter get an X prefix.
+func exportedName(camel string) string {
+	first, size := utf8.DecodeRuneInString(camel)
+	if camel == "" || !unicode.IsLetter(first) {
+		return "X" + camel
+	}
+	return string(unicode.ToUpper(first)) + camel[size:]
+}
+
+// uniqueFieldNames maps each column or UDT field name to its Go field name.
+// Quoted identifiers such as userid and "userId" can camel-case to the same
+// field, so later duplicates get the lowest free numeric suffix (UserId2).
+// Names in overrides are used as given, and derived names that clash with
+// them are suffixed instead.
+func uniqueFieldNames(identifiers []string, overrides map[string]string) map[string]string {
+	names := make(map[string]string, len(identifiers))
+	taken := make(map[string]bool, len(identifiers))
+	used := make(map[string]bool, len(identifiers))
+
+	for _, identifier := range identifiers {
+		if name, ok := overrides[identifier]; ok {
+			used[name] = true
+			names[identifier] = name
+		}
+		taken[fieldName(identifier)] = true
+	}
+
+	for _, identifier := range identifiers {
+		if _, ok := overrides[identifier]; ok {
+			continue
+		}
+
+		name := fieldName(identifier)
+		if used[name] {
+			for n := 2; ; n++ {
+				candidate := name + strconv.Itoa(n)
+				if !taken[candidate] && !used[candidate] {
+					name = candidate
+					break
+				}
+			}
+		}
+
+		used[name] = true
+		names[identifier] = name
+	}
+
+	return names
+}
+
+func columnNames(columns []column) []string {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// generateFieldMap emits a map from each generated Go field name to the CQL
+// column it was derived from.
+func generateFieldMap(structName string, columns []column, fields map[string]string) string {
+	fieldMap := fmt.Sprintf("var %sFieldMap = map[string]string{\n", structName)
+	for _, col := range columns {
+		fieldMap += fmt.Sprintf("    %q: %q,\n", fields[col.Name], col.Name)
+	}
+	fieldMap += "}\n"
+	return fieldMap
+}
+
+const timeOfDayNote = "time of day as nanoseconds since midnight, not a duration"
+
+// isTimeOfDay reports whether col is a CQL time column, which maps to
+// time.Duration.
+func isTimeOfDay(col column) bool {
+	return strings.EqualFold(strings.TrimSpace(col.Type), "time")
+}
+
+// fieldComment describes a column's CQL type and any semantics that the Go
+// type alone does not convey.
+func fieldComment(col column) string {
+	comment := col.Type
+	if strings.EqualFold(strings.TrimSpace(col.Type), "timeuuid") {
+		comment += ", time-based (version 1) UUID"
+	}
+	if isTimeOfDay(col) {
+		comment += ", " + timeOfDayNote
+	}
+	if col.Kind == "static" {
+		comment += ", static (shared by all rows in the partition)"
+	}
+	return comment
+}
+
+// generateUDTStruct emits the struct for a user-defined type. Fields carry
+// cql tags so gocql's UDT marshaling maps them to the type's field names, and
+// json tags unless opts.NoJSON is set. The struct is named as opts.Types
+// resolves the type.
+func generateUDTStruct(udt udtSchema, opts structOptions) (string, error) {
+	mapper := opts.Types
+	structDefinition := fmt.Sprintf("type %s struct {\n", mapper.UDTs[udt.Name])
+
+	var fieldNames []string
+	for _, field := range udt.Fields {
+		fieldNames = append(fieldNames, field.Name)
+	}
+	names := uniqueFieldNames(fieldNames, nil)
+
+	for _, field := range udt.Fields {
+		goType, err := mapper.cqlToGoType(field.Type)
+		if err != nil {
+			return "", fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		tag := fmt.Sprintf("cql:\"%s\"", field.Name)
+		if !opts.NoJSON {
+			tag += fmt.Sprintf(" json:\"%s\"", jsonTagName(field.Name, opts.JSONCase))
+		}
+		for _, extra := range opts.ExtraTags {
+			tag += fmt.Sprintf(" %s:\"%s\"", extra.Key, jsonTagName(field.Name, extra.Case))
+		}
+		structDefinition += fmt.Sprintf("    %s %s `%s`\n", names[field.Name], goType, tag)
+	}
+
+	structDefinition += "}\n"
+	return structDefinition, nil
+}
+
+// generateUDTMarshalers emits MarshalUDT and UnmarshalUDT for the struct of
+// a user-defined type, so gocql encodes its fields through a switch on the
+// field name instead of matching cql tags by reflection. Cases follow the
+// field order of the type definition. gocql calls the methods once per field
+// in the order of the type the server sends and writes fields the struct
+// lacks as null. MarshalUDT has a value receiver because gocql only finds it
+// on values.
+func generateUDTMarshalers(udt udtSchema, structName string) string {
+	receiver := receiverName(structName)
+
+	var fieldNames []string
+	for _, field := range udt.Fields {
+		fieldNames = append(fieldNames, field.Name)
+	}
+	names := uniqueFieldNames(fieldNames, nil)
+
+	code := "// MarshalUDT implements gocql.UDTMarshaler.\n"
+	code += fmt.Sprintf("func (%s %s) MarshalUDT(name string, info gocql.TypeInfo) ([]byte, error) {\n", receiver, structName)
+	code += "    switch name {\n"
+	for _, field := range udt.Fields {
+		code += fmt.Sprintf("    case %q:\n", field.Name)
+		code += fmt.Sprintf("        return gocql.Marshal(info, %s.%s)\n", receiver, names[field.Name])
+	}
+	code += "    }\n"
+	code += "    return nil, nil\n"
+	code += "}\n\n"
+
+	code += "// UnmarshalUDT implements gocql.UDTUnmarshaler. Fields the struct does\n"
+	code += "// not know are skipped.\n"
+	code += fmt.Sprintf("func (%s *%s) UnmarshalUDT(name string, info gocql.TypeInfo, data []byte) error {\n", receiver, structName)
+	code += "    switch name {\n"
+	for _, field := range udt.Fields {
+		code += fmt.Sprintf("    case %q:\n", field.Name)
+		code += fmt.Sprintf("        return gocql.Unmarshal(info, data, &%s.%s)\n", receiver, names[field.Name])
+	}
+	code += "    }\n"
+	code += "    return nil\n"
+	code += "}\n"
+	return code
+}
+
+// docCommentWidth is the line width that table comments are wrapped to.
+const docCommentWidth = 76
+
+// wrapComment renders text as // comment lines of at most width characters,
+// keeping the text's own line breaks. Words longer than width are not split.
+func wrapComment(text string, width int) string {
+	comment := ""
+	for _, paragraph := range strings.Split(strings.TrimSpace(text), "\n") {
+		line := "//"
+		for _, word := range strings.Fields(paragraph) {
+			if line != "//" && len(line)+1+len(word) > width {
+				comment += line + "\n"
+				line = "//"
+			}
+			line += " " + word
+		}
+		comment += line + "\n"
+	}
+	return comment
+}
+
+// nullTypes lists the supported -nullType modes.
+var nullTypes = []string{"none", "pointers", "sql"}
+
+func validateNullType(nullType string) error {
+	if !slices.Contains(nullTypes, nullType) {
+		return fmt.Errorf("unknown null type %q: expected one of %s", nullType, strings.Join(nullTypes, ", "))
+	}
+	return nil
+}
+
+// parsePointerColumns parses a -pointerColumns list of table.column entries,
+// each optionally prefixed with ! to force a plain type, into per-table
+// overrides.
+func parsePointerColumns(spec string) (map[string]map[string]bool, error) {
+	overrides := make(map[string]map[string]bool)
+	for _, entry := range splitList(spec) {
+		pointer := !strings.HasPrefix(entry, "!")
+		tableName, columnName, ok := strings.Cut(strings.TrimPrefix(entry, "!"), ".")
+		if !ok || tableName == "" || columnName == "" {
+			return nil, fmt.Errorf("invalid -pointerColumns entry %q: expected table.column or !table.column", entry)
+		}
+		if overrides[tableName] == nil {
+			overrides[tableName] = make(map[string]bool)
+		}
+		overrides[tableName][columnName] = pointer
+	}
+	return overrides, nil
+}
+
+// sqlNullTypes are the database/sql null types named after a Go type; other
+// types use the generic sql.Null[T], which needs Go 1.22.
+var sqlNullTypes = map[string]string{
+	"string":    "sql.NullString",
+	"bool":      "sql.NullBool",
+	"int16":     "sql.NullInt16",
+	"int32":     "sql.NullInt32",
+	"int64":     "sql.NullInt64",
+	"float64":   "sql.NullFloat64",
+	"time.Time": "sql.NullTime",
+}
+
+// nullableType returns the Go type of a nullable scalar column in the given
+// -nullType mode. gocql cannot scan into database/sql null types, so "sql"
+// suits structs that are mainly used outside direct gocql scanning.
+func nullableType(goType string, nullType string) string {
+	switch nullType {
+	case "pointers":
+		return "*" + goType
+	case "sql":
+		if sqlType, ok := sqlNullTypes[goType]; ok {
+			return sqlType
+		}
+		return "sql.Null[" + goType + "]"
+	default:
+		return goType
+	}
+}
+
+// jsonCases lists the supported -jsonCase styles, which -extraTags also
+// accepts.
+var jsonCases = []string{"column", "camel", "lower", "snake", "upper_snake"}
+
+func validateJSONCase(jsonCase string) error {
+	if !slices.Contains(jsonCases, jsonCase) {
+		return fmt.Errorf("unknown json case %q: expected one of %s", jsonCase, strings.Join(jsonCases, ", "))
+	}
+	return nil
+}
+
+// jsonTagName derives a json tag name from a column or field name: "column"
+// keeps it as is, "camel" gives userId, "lower" strips separators and
+// lowercases, giving userid, "snake" gives user_id and "upper_snake" gives
+// USER_ID.
+func jsonTagName(name string, jsonCase string) string {
+	switch jsonCase {
+	case "camel":
+		return strcase.ToLowerCamel(name)
+	case "lower":
+		return strings.ToLower(strings.NewReplacer("_", "", "-", "", " ", "").Replace(name))
+	case "snake":
+		return strcase.ToSnake(name)
+	case "upper_snake":
+		return strcase.ToScreamingSnake(name)
+	default:
+		return name
+	}
+}
+
+// extraTag is a struct tag added by -extraTags, with its value derived from
+// the column name in one of the jsonCases styles.
+type extraTag struct {
+	Key  string
+	Case string
+}
+
+var extraTagKey = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// parseExtraTags parses a -extraTags list of key=style entries, sorted by key
+// so the tags come out in a stable order. The style "original" is an alias
+// for "column"; keys the generator writes itself are rejected.
+func parseExtraTags(spec string) ([]extraTag, error) {
+	var tags []extraTag
+	seen := make(map[string]bool)
+
+	for _, entry := range splitList(spec) {
+		key, tagCase, ok := strings.Cut(entry, "=")
+		if !ok || !extraTagKey.MatchString(key) {
+			return nil, fmt.Errorf("invalid -extraTags entry %q: expected key=style", entry)
+		}
+		switch key {
+		case "json", "db", "cql", "validate":
+			return nil, fmt.Errorf("invalid -extraTags entry %q: the %s tag is generated by its own option", entry, key)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate -extraTags key %q", key)
+		}
+		seen[key] = true
+
+		if tagCase == "original" {
+			tagCase = "column"
+		}
+		if !slices.Contains(jsonCases, tagCase) {
+			return nil, fmt.Errorf("unknown -extraTags style %q for %s: expected original or one of %s", tagCase, key, strings.Join(jsonCases, ", "))
+		}
+
+		tags = append(tags, extraTag{Key: key, Case: tagCase})
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+	return tags, nil
+}
+
+// clusteringOrder returns ASC or DESC for a clustering column, as recorded in
+// system_schema.columns.clustering_order.
+func clusteringOrder(col column) string {
+	if strings.EqualFold(col.ClusteringOrder, "desc") {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+func isPrimaryKey(col column) bool {
+	return col.Kind == "partition_key" || col.Kind == "clustering"
+}
+
+// isNilable reports whether a generated Go type can already hold nil.
+func isNilable(goType string) bool {
+	return strings.HasPrefix(goType, "[]") || goType == "net.IP" || strings.HasPrefix(goType, "map[") || strings.HasPrefix(goType, "*") || isOrderedMapType(goType)
+}
+
+// generateOptions controls what is generated for each keyspace. With
+// PrefixKeyspace, every generated type and variable name starts with the
+// Pascal-cased keyspace name so several keyspaces can share a package.
+type generateOptions struct {
+	Struct               structOptions
+	Gocqlx               bool
+	FieldMap             bool
+	EmbedColumns         []string
+	EmbedName            string
+	GocqlImport          string
+	BuildTags            string
+	Registry             bool
+	Indent               string
+	NoFormat             bool
+	SplitFiles           bool
+	RoutingKey           bool
+	PartitionKeyStruct   bool
+	IterWrapper          bool
+	Insert               bool
+	Methods              bool
+	IndexMethods         bool
+	BatchHelpers         bool
+	BatchType            string
+	Fingerprint          bool
+	PointerColumns       map[string]map[string]bool
+	FieldNames           map[string]map[string]string
+	Constructors         bool
+	Defaults             map[string]map[string]string
+	Package              string
+	PrefixKeyspace       bool
+	DocComments          bool
+	Accessors            bool
+	JSONEmptyCollections bool
+	UDTMarshalers        bool
+	Header               string
+	Partial              bool
+}
+
+// typePrefix returns the prefix for the Go names generated for keyspace.
+func (opts generateOptions) typePrefix(keyspace string) string {
+	if opts.PrefixKeyspace {
+		return toPascal(keyspace)
+	}
+	return ""
+}
+
+// commentHeader turns the contents of a -headerFile into a comment block
+// followed by a blank line. The text is kept as is when it is already a
+// block comment; otherwise lines that are not // comments get "// " added.
+func commentHeader(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+	if strings.HasPrefix(text, "/*") {
+		return text + "\n\n"
+	}
+
+	header := ""
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(line), "//"):
+			header += line + "\n"
+		case line == "":
+			header += "//\n"
+		default:
+			header += "// " + line + "\n"
+		}
+	}
+	return header + "\n"
+}
+
+// validatePackageName checks that name can be used as a package clause.
+func validatePackageName(name string) error {
+	if !token.IsIdentifier(name) || name == "_" {
+		return fmt.Errorf("invalid package name %q", name)
+	}
+	return nil
+}
+
+// validateBuildTags checks that expr is a valid //go:build expression.
+func validateBuildTags(expr string) error {
+	if _, err := constraint.Parse("//go:build " + expr); err != nil {
+		return fmt.Errorf("invalid build tag expression %q: %w", expr, err)
+	}
+	return nil
+}
+
+// partialNote returns the header line marking output generated from an
+// incomplete schema, or nothing.
+func partialNote(opts generateOptions) string {
+	if !opts.Partial {
+		return ""
+	}
+	return "// PARTIAL: the schema fetch timed out, so some tables may be missing.\n"
+}
+
+// generatedHeader marks files written by this tool. Only files carrying it
+// are ever overwritten or cleaned up.
+const generatedHeader = "// Code generated by go-cql-scaffold. DO NOT EDIT."
+
+// keyspaceCode holds the rendered declarations of a keyspace before they are
+// assembled into files. Shared holds declarations used across tables (UDTs
+// and the embedded struct), Trailer holds keyspace-wide helpers. TypeNames
+// are the Go names of the user-defined types, in schema order.
+type keyspaceCode struct {
+	Shared    []string
+	TypeNames []string
+	Tables    []tableCode
+	Trailer   []string
+}
+
+type tableCode struct {
+	Name         string
+	StructName   string
+	Declarations []string
+}
+
+func generateKeyspaceCode(schema keyspaceSchema, opts generateOptions) (keyspaceCode, error) {
+	var code keyspaceCode
+
+	prefix := opts.typePrefix(schema.Name)
+	opts.Struct.Types = opts.Struct.Types.withUserTypes(schema.Types, prefix)
+
+	for _, udt := range schema.Types {
+		udtDef, err := generateUDTStruct(udt, opts.Struct)
+		if err != nil {
+			return code, fmt.Errorf("type %s: %w", udt.Name, err)
+		}
+
+		code.Shared = append(code.Shared, udtDef)
+		code.TypeNames = append(code.TypeNames, opts.Struct.Types.UDTs[udt.Name])
+
+		if opts.UDTMarshalers {
+			code.Shared = append(code.Shared, generateUDTMarshalers(udt, opts.Struct.Types.UDTs[udt.Name]))
+		}
+
+		if opts.JSONEmptyCollections {
+			var fieldNames []string
+			var fieldColumns []column
+			for _, field := range udt.Fields {
+				fieldNames = append(fieldNames, field.Name)
+				fieldColumns = append(fieldColumns, column{Name: field.Name, Type: field.Type})
+			}
+
+			marshaler, err := generateJSONMarshaler(opts.Struct.Types.UDTs[udt.Name], fieldColumns, uniqueFieldNames(fieldNames, nil), opts.Struct.Types)
+			if err != nil {
+				return code, fmt.Errorf("type %s: %w", udt.Name, err)
+			}
+			if marshaler != "" {
+				code.Shared = append(code.Shared, marshaler)
+			}
+		}
+	}
+
+	var embed embedSpec
+	var hasEmbed bool
+	if len(opts.EmbedColumns) > 0 {
+		embed, hasEmbed = resolveEmbed(opts.EmbedName, opts.EmbedColumns, schema.Tables)
+		embed.Name = prefix + embed.Name
+
+		if hasEmbed {
+			structDef, err := generateGoStruct(embed.Name, embed.Columns, opts.Struct)
+			if err != nil {
+				return code, err
+			}
+
+			code.Shared = append(code.Shared, structDef)
+
+			if opts.Accessors {
+				accessors, err := generateAccessors(embed.Name, embed.Columns, uniqueFieldNames(columnNames(embed.Columns), nil), opts.Struct.Types)
+				if err != nil {
+					return code, err
+				}
+				if accessors != "" {
+					code.Shared = append(code.Shared, accessors)
+				}
+			}
+		} else {
+			log.Printf("No table in keyspace %s contains all -embed columns, skipping %s", schema.Name, opts.EmbedName)
+		}
+	}
+
+	for _, table := range schema.Tables {
+		for _, col := range table.Columns {
+			if isReservedKeyword(col.Name) {
+				log.Printf("Warning: column %s.%s is a reserved CQL keyword; generated queries quote it", table.Name, col.Name)
+			}
+		}
+
+		columns := table.Columns
+		tableOpts := opts.Struct
+		tableOpts.PointerColumns = opts.PointerColumns[table.Name]
+		tableOpts.FieldNames = opts.FieldNames[table.Name]
+
+		for columnName := range tableOpts.PointerColumns {
+			if col, ok := findColumn(columns, columnName); !ok || isPrimaryKey(col) {
+				log.Printf("Warning: -pointerColumns entry %s.%s does not name a non-key column", table.Name, columnName)
+			}
+		}
+		for columnName := range tableOpts.FieldNames {
+			if _, ok := findColumn(columns, columnName); !ok {
+				log.Printf("Warning: -fieldNames entry %s.%s does not name a column", table.Name, columnName)
+			}
+		}
+
+		// The shared struct uses the global null typing and derived field
+		// names, so a table that overrides either for a shared column keeps
+		// its own fields.
+		overridesEmbed := false
+		for _, col := range embed.Columns {
+			if _, ok := tableOpts.PointerColumns[col.Name]; ok {
+				overridesEmbed = true
+			}
+			if _, ok := tableOpts.FieldNames[col.Name]; ok {
+				overridesEmbed = true
+			}
+		}
+
+		if hasEmbed && !overridesEmbed && embed.matches(columns) {
+			columns = embed.strip(columns)
+			tableOpts.Embed = embed.Name
+		}
+
+		// Field names as the struct exposes them, including promoted fields
+		// of the embedded struct.
+		names := uniqueFieldNames(columnNames(columns), tableOpts.FieldNames)
+		if tableOpts.Embed != "" {
+			for columnName, name := range uniqueFieldNames(columnNames(embed.Columns), nil) {
+				for overridden, override := range tableOpts.FieldNames {
+					if override == name && overridden != columnName {
+						return code, fmt.Errorf("table %s: -fieldNames entry %s collides with the embedded field %s", table.Name, overridden, name)
+					}
+				}
+				names[columnName] = name
+			}
+		}
+		for _, col := range table.Columns {
+			if _, ok := tableOpts.FieldNames[col.Name]; ok {
+				continue
+			}
+			for overridden, override := range tableOpts.FieldNames {
+				if override == fieldName(col.Name) {
+					return code, fmt.Errorf("table %s: -fieldNames entry %s collides with the field of column %s", table.Name, overridden, col.Name)
+				}
+			}
+			if names[col.Name] != fieldName(col.Name) {
+				log.Printf("Warning: columns of %s share the Go field name %s; column %s becomes %s", table.Name, fieldName(col.Name), col.Name, names[col.Name])
+			}
+		}
+
+		structName := prefix + toPascal(table.Name)
+
+		if opts.DocComments {
+			tableOpts.Doc = fmt.Sprintf("%s maps the %s table.", structName, table.Name)
+			if comment := strings.TrimSpace(table.Comment); comment != "" {
+				tableOpts.Doc = comment
+			}
+		}
+
+		structDef, err := generateGoStruct(structName, columns, tableOpts)
+		if err != nil {
+			return code, fmt.Errorf("table %s: %w", table.Name, err)
+		}
+
+		tc := tableCode{Name: table.Name, StructName: structName, Declarations: []string{structDef}}
+
+		if opts.Accessors {
+			accessors, err := generateAccessors(structName, columns, names, opts.Struct.Types)
+			if err != nil {
+				return code, fmt.Errorf("table %s: %w", table.Name, err)
+			}
+			if accessors != "" {
+				tc.Declarations = append(tc.Declarations, accessors)
+			}
+		}
+
+		if opts.JSONEmptyCollections {
+			// Embedded collections are promoted, so the table's method
+			// covers them and the shared struct gets none.
+			marshaler, err := generateJSONMarshaler(structName, table.Columns, names, opts.Struct.Types)
+			if err != nil {
+				return code, fmt.Errorf("table %s: %w", table.Name, err)
+			}
+			if marshaler != "" {
+				tc.Declarations = append(tc.Declarations, marshaler)
+			}
+		}
+
+		if opts.Constructors {
+			for columnName := range opts.Defaults[table.Name] {
+				if _, ok := findColumn(table.Columns, columnName); !ok {
+					log.Printf("Warning: -defaults entry %s.%s does not name a column", table.Name, columnName)
+				}
+			}
+
+			// Embedded columns are typed by the shared struct's options.
+			fieldColumns := columns
+			fieldTypes := make(map[string]string)
+			if tableOpts.Embed != "" {
+				fieldColumns = append(append([]column{}, embed.Columns...), columns...)
+				for _, col := range embed.Columns {
+					if fieldTypes[col.Name], err = opts.Struct.fieldType(col); err != nil {
+						return code, fmt.Errorf("table %s: %w", table.Name, err)
+					}
+				}
+			}
+			for _, col := range columns {
+				if fieldTypes[col.Name], err = tableOpts.fieldType(col); err != nil {
+					return code, fmt.Errorf("table %s: %w", table.Name, err)
+				}
+			}
+
+			constructor, err := generateConstructor(structName, fieldColumns, names, fieldTypes, opts.Defaults[table.Name])
+			if err != nil {
+				return code, fmt.Errorf("table %s: %w", table.Name, err)
+			}
+			tc.Declarations = append(tc.Declarations, constructor)
+		}
+
+		if opts.Gocqlx {
+			tc.Declarations = append(tc.Declarations, generateGocqlxHelpers(schema.Name, table.Name, structName, table.Columns))
+		}
+
+		if opts.FieldMap {
+			tc.Declarations = append(tc.Declarations, generateFieldMap(structName, table.Columns, names))
+		}
+
+		if opts.RoutingKey {
+			tc.Declarations = append(tc.Declarations, generateRoutingKey(structName, table.Columns, names))
+		}
+
+		if opts.PartitionKeyStruct {
+			partition, err := generatePartitionStruct(structName, table.Columns, names, opts.Struct.Types)
+			if err != nil {
+				return code, fmt.Errorf("table %s: %w", table.Name, err)
+			}
+			if partition != "" {
+				tc.Declarations = append(tc.Declarations, partition)
+			}
+		}
+
+		if opts.IterWrapper {
+			// Scan in struct field order: embedded columns come first.
+			scanColumns := columns
+			if tableOpts.Embed != "" {
+				scanColumns = append(append([]column{}, embed.Columns...), columns...)
+			}
+			tc.Declarations = append(tc.Declarations, generateIterWrapper(structName, scanColumns, names))
+		}
+
+		// -methods and -batchHelpers build on the -insert declarations.
+		insertable := false
+		if opts.Insert || opts.Methods || opts.BatchHelpers {
+			if insert, ok := generateInsert(schema.Name, table.Name, structName, table.Columns, names); ok {
+				tc.Declarations = append(tc.Declarations, insert)
+				insertable = true
+				if opts.BatchHelpers {
+					tc.Declarations = append(tc.Declarations, generateInsertBatch(structName, opts.BatchType))
+				}
+			} else {
+				log.Printf("Table %s has counter columns, which cannot be inserted; skipping its insert helpers", table.Name)
+			}
+		}
+
+		if opts.Methods {
+			// Scan in struct field order: embedded columns come first.
+			scanColumns := columns
+			if tableOpts.Embed != "" {
+				scanColumns = append(append([]column{}, embed.Columns...), columns...)
+			}
+			repo, err := generateRepo(schema.Name, table.Name, structName, scanColumns, names, opts.Struct.Types, insertable)
+			if err != nil {
+				return code, fmt.Errorf("table %s: %w", table.Name, err)
+			}
+			tc.Declarations = append(tc.Declarations, repo)
+
+			if opts.IndexMethods {
+				indexMethods, err := generateIndexMethods(schema.Name, table.Name, structName, scanColumns, names, opts.Struct.Types, table.Indexes)
+				if err != nil {
+					return code, fmt.Errorf("table %s: %w", table.Name, err)
+				}
+				if indexMethods != "" {
+					tc.Declarations = append(tc.Declarations, indexMethods)
+				}
+			}
+		}
+
+		for _, col := range table.Columns {
+			if values, ok := table.EnumValues[col.Name]; ok {
+				tc.Declarations = append(tc.Declarations, generateEnumConstants(structName, names[col.Name], values))
+			}
+		}
+
+		code.Tables = append(code.Tables, tc)
+	}
+
+	var declarations []string
+	declarations = append(declarations, code.Shared...)
+	for _, table := range code.Tables {
+		declarations = append(declarations, table.Declarations...)
+	}
+	if name := opts.Struct.Types.OrderedMap; usesOrderedMap(name, declarations) {
+		code.Shared = append(code.Shared, generateOrderedMap(name))
+	}
+
+	if opts.Registry {
+		code.Trailer = append(code.Trailer, generateRegistry(prefix, schema.Tables))
+	}
+
+	if opts.Fingerprint {
+		code.Trailer = append(code.Trailer, generateFingerprint(prefix, schema))
+	}
+
+	return code, nil
+}
+
+// generateKeyspaceFile renders a complete, gofmt-formatted Go source file
+// holding the structs and helpers for every table of a keyspace.
+func generateKeyspaceFile(schema keyspaceSchema, opts generateOptions) ([]byte, error) {
+	code, err := generateKeyspaceCode(schema, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	declarations := code.Shared
+	for _, table := range code.Tables {
+		declarations = append(declarations, table.Declarations...)
+	}
+	declarations = append(declarations, code.Trailer...)
+
+	return renderGoFile(declarations, opts)
+}
+
+// File names of the keyspace-wide declarations and the package overview in
+// split-file mode.
+const (
+	sharedFileName = "types.go"
+	docFileName    = "doc.go"
+)
+
+// generateSplitFiles renders one file per table plus a shared file for
+// user-defined types and keyspace-wide helpers, and a doc.go indexing them.
+// The returned paths are file names relative to the package directory, which
+// start with the keyspace when PrefixKeyspace lets keyspaces share it.
+func generateSplitFiles(schema keyspaceSchema, opts generateOptions) ([]generatedFile, error) {
+	code, err := generateKeyspaceCode(schema, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	filePrefix := ""
+	if opts.PrefixKeyspace {
+		filePrefix = strings.ToLower(sanitizePathComponent(schema.Name)) + "_"
+	}
+	sharedFile, docFile := filePrefix+sharedFileName, filePrefix+docFileName
+
+	var files []generatedFile
+
+	if shared := append(code.Shared, code.Trailer...); len(shared) > 0 {
+		content, err := renderGoFile(shared, opts)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, generatedFile{Path: sharedFile, Content: content})
+	}
+
+	tableFiles := make([]string, len(code.Tables))
+	for i, table := range code.Tables {
+		content, err := renderGoFile(table.Declarations, opts)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", table.Name, err)
+		}
+
+		name := filePrefix + strings.ToLower(sanitizePathComponent(table.Name)) + ".go"
+		if name == sharedFile || name == docFile {
+			name = strings.TrimSuffix(name, ".go") + "_table.go"
+		}
+		tableFiles[i] = name
+		files = append(files, generatedFile{Path: name, Content: content, Table: schema.Name + "." + table.Name})
+	}
+
+	doc, err := renderDocFile(schema.Name, code, sharedFile, tableFiles, opts)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, generatedFile{Path: docFile, Content: doc})
+
+	return files, nil
+}
+
+// renderDocFile renders the package doc comment of a split keyspace, which
+// lists each table's struct and file and the user-defined types so go doc
+// gives an overview of the package.
+func renderDocFile(keyspace string, code keyspaceCode, sharedFile string, tableFiles []string, opts generateOptions) ([]byte, error) {
+	doc := fmt.Sprintf("// Package %s holds the types generated from the %s keyspace.\n", opts.Package, keyspace)
+
+	if len(code.Tables) > 0 {
+		doc += "//\n// Tables, one file each:\n//\n"
+		for i, table := range code.Tables {
+			doc += fmt.Sprintf("//   - [%s] in %s maps table %s\n", table.StructName, tableFiles[i], table.Name)
+		}
+	}
+
+	if len(code.TypeNames) > 0 {
+		doc += fmt.Sprintf("//\n// User-defined types, in %s:\n//\n", sharedFile)
+		for _, name := range code.TypeNames {
+			doc += fmt.Sprintf("//   - [%s]\n", name)
+		}
+	}
+
+	source := opts.Header + generatedHeader + "\n" + partialNote(opts) + "\n"
+	if opts.BuildTags != "" {
+		source += "//go:build " + opts.BuildTags + "\n\n"
+	}
+	source += doc + "package " + opts.Package + "\n"
+
+	if opts.NoFormat {
+		return []byte(source), nil
+	}
+	return format.Source([]byte(source))
+}
+
+// renderGoFile assembles declarations into a gofmt-formatted source file with
+// the generated-code header, package clause and the imports they need. With
+// NoFormat the raw source is returned as is.
+func renderGoFile(declarations []string, opts generateOptions) ([]byte, error) {
+	code := strings.Join(declarations, "\n")
+
+	source := opts.Header + generatedHeader + "\n" + partialNote(opts) + "\n"
+	if opts.BuildTags != "" {
+		source += "//go:build " + opts.BuildTags + "\n\n"
+	}
+	source += "package " + opts.Package + "\n\n"
+	imports, err := generateImports(code, opts.Gocqlx, opts.GocqlImport)
+	if err != nil && !opts.NoFormat {
+		return nil, fmt.Errorf("parsing generated code (rerun with -noFormat to inspect it): %w", err)
+	}
+	if imports != "" {
+		source += imports + "\n"
+	}
+	source += code
+	source = reindent(source, opts.Indent)
+
+	if opts.NoFormat {
+		return []byte(source), nil
+	}
+
+	formatted, err := format.Source([]byte(source))
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code (rerun with -noFormat to inspect it): %w", err)
+	}
+
+	return formatted, nil
+}
+
+// rawIndent is the indentation unit the generators emit.
+const rawIndent = "    "
+
+// parseIndent turns an -indent value, "tab" or a number of spaces, into the
+// indentation unit to use in generated source.
+func parseIndent(value string) (string, error) {
+	if value == "tab" {
+		return "\t", nil
+	}
+
+	spaces, err := strconv.Atoi(value)
+	if err != nil || spaces < 1 || spaces > 16 {
+		return "", fmt.Errorf("invalid indent %q: expected tab or a number of spaces between 1 and 16", value)
+	}
+	return strings.Repeat(" ", spaces), nil
+}
+
+// reindent replaces the generators' leading indentation with indent. An empty
+// indent leaves the source unchanged. The go/format pass normalizes
+// indentation to tabs afterwards, so this only shows when formatting is off.
+func reindent(source string, indent string) string {
+	if indent == "" || indent == rawIndent {
+		return source
+	}
+
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		depth := 0
+		for strings.HasPrefix(line[depth*len(rawIndent):], rawIndent) {
+			depth++
+		}
+		lines[i] = strings.Repeat(indent, depth) + line[depth*len(rawIndent):]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// enumValueSeparators are the runs of characters in an enum value that do not
+// carry over into its constant name.
+var enumValueSeparators = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// generateEnumConstants emits a string constant for each observed value of a
+// column. Values that yield the same identifier are numbered to stay unique.
+func generateEnumConstants(structName string, field string, values []string) string {
+	prefix := structName + field
+	seen := make(map[string]int)
+
+	constants := "const (\n"
+	for _, value := range values {
+		suffix := strcase.ToCamel(enumValueSeparators.ReplaceAllString(value, "_"))
+		if suffix == "" {
+			suffix = "Empty"
+		}
+
+		name := prefix + suffix
+		seen[name]++
+		if seen[name] > 1 {
+			name += strconv.Itoa(seen[name])
+		}
+
+		constants += fmt.Sprintf("    %s = %q\n", name, value)
+	}
+	constants += ")\n"
+	return constants
+}
+
+// nativeTypeNames maps scalar CQL types to the gocql Type constant used to
+// marshal them.
+var nativeTypeNames = map[string]string{
+	"ascii":     "TypeAscii",
+	"bigint":    "TypeBigInt",
+	"blob":      "TypeBlob",
+	"boolean":   "TypeBoolean",
+	"counter":   "TypeCounter",
+	"date":      "TypeDate",
+	"decimal":   "TypeDecimal",
+	"double":    "TypeDouble",
+	"duration":  "TypeDuration",
+	"float":     "TypeFloat",
+	"inet":      "TypeInet",
+	"int":       "TypeInt",
+	"smallint":  "TypeSmallInt",
+	"text":      "TypeText",
+	"time":      "TypeTime",
+	"timestamp": "TypeTimestamp",
+	"timeuuid":  "TypeTimeUUID",
+	"tinyint":   "TypeTinyInt",
+	"uuid":      "TypeUUID",
+	"varchar":   "TypeVarchar",
+	"varint":    "TypeVarint",
+}
+
+// routingProtoVersion is the native protocol version used to marshal routing
+// key components; scalar encodings are identical across v3 and later.
+const routingProtoVersion = 4
+
+// generatePartitionStruct emits, for a table with a composite partition key,
+// a <Struct>Partition struct of the partition key fields in partition order,
+// a Partition method extracting it from a row and SetPartition copying one
+// into a row. Tables with a single partition key column get nothing, as do
+// tables with a field of either method's name, which is logged.
+func generatePartitionStruct(structName string, columns []column, fields map[string]string, mapper typeMapper) (string, error) {
+	var partitionKey []column
+	for _, col := range columns {
+		if col.Kind == "partition_key" {
+			partitionKey = append(partitionKey, col)
+		}
+	}
+	if len(partitionKey) < 2 {
+		return "", nil
+	}
+	sort.SliceStable(partitionKey, func(i, j int) bool {
+		return partitionKey[i].Position < partitionKey[j].Position
+	})
+
+	for _, col := range columns {
+		if field := fields[col.Name]; field == "Partition" || field == "SetPartition" {
+			log.Printf("Warning: %s has a field named %s; skipping its partition key struct", structName, field)
+			return "", nil
+		}
+	}
+
+	typeName := structName + "Partition"
+	receiver := receiverName(structName)
+
+	code := fmt.Sprintf("// %s holds the partition key of %s rows, in partition order.\n", typeName, structName)
+	code += fmt.Sprintf("type %s struct {\n", typeName)
+	for _, col := range partitionKey {
+		goType, err := mapper.cqlToGoType(col.Type)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		code += fmt.Sprintf("    %s %s\n", fields[col.Name], goType)
+	}
+	code += "}\n\n"
+
+	code += "// Partition returns the partition key of the row.\n"
+	code += fmt.Sprintf("func (%s %s) Partition() %s {\n", receiver, structName, typeName)
+	code += fmt.Sprintf("    return %s{\n", typeName)
+	for _, col := range partitionKey {
+		code += fmt.Sprintf("        %s: %s.%s,\n", fields[col.Name], receiver, fields[col.Name])
+	}
+	code += "    }\n"
+	code += "}\n\n"
+
+	code += "// SetPartition sets the partition key fields of the row from key.\n"
+	code += fmt.Sprintf("func (%s *%s) SetPartition(key %s) {\n", receiver, structName, typeName)
+	for _, col := range partitionKey {
+		code += fmt.Sprintf("    %s.%s = key.%s\n", receiver, fields[col.Name], fields[col.Name])
+	}
+	code += "}\n"
+	return code, nil
+}
+
+// generateRoutingKey emits the partition key column names in partition order
+// and, when every partition key column is a scalar, a RoutingKey method that
+// serializes them the way the cluster computes the partition token.
+func generateRoutingKey(structName string, columns []column, fields map[string]string) string {
+	var partitionKey []column
+	for _, col := range columns {
+		if col.Kind == "partition_key" {
+			partitionKey = append(partitionKey, col)
+		}
+	}
+	sort.SliceStable(partitionKey, func(i, j int) bool {
+		return partitionKey[i].Position < partitionKey[j].Position
+	})
+
+	var names []string
+	for _, col := range partitionKey {
+		names = append(names, col.Name)
+	}
+
+	code := fmt.Sprintf("// %sPartitionKey lists the partition key columns in partition order.\n", structName)
+	code += fmt.Sprintf("var %sPartitionKey = %s\n", structName, goStringSlice(names))
+
+	var components []string
+	for _, col := range partitionKey {
+		typeName, ok := nativeTypeNames[strings.ToLower(strings.TrimSpace(col.Type))]
+		if !ok {
+			return code
+		}
+		components = append(components, fmt.Sprintf("gocql.NewNativeType(%d, gocql.%s, \"\"), %s.%s", routingProtoVersion, typeName, receiverName(structName), fields[col.Name]))
+	}
+	if len(components) == 0 {
+		return code
+	}
+
+	receiver := receiverName(structName)
+	code += "\n// RoutingKey serializes the partition key for token-aware routing.\n"
+	code += fmt.Sprintf("func (%s %s) RoutingKey() ([]byte, error) {\n", receiver, structName)
+
+	if len(components) == 1 {
+		code += fmt.Sprintf("    return gocql.Marshal(%s)\n", components[0])
+		code += "}\n"
+		return code
+	}
+
+	code += "    components := []struct {\n"
+	code += "        info  gocql.TypeInfo\n"
+	code += "        value interface{}\n"
+	code += "    }{\n"
+	for _, component := range components {
+		code += fmt.Sprintf("        {%s},\n", component)
+	}
+	code += "    }\n\n"
+	code += "    // Composite keys encode each component as a 2-byte length, the\n"
+	code += "    // value and a zero byte.\n"
+	code += "    var key []byte\n"
+	code += "    for _, component := range components {\n"
+	code += "        encoded, err := gocql.Marshal(component.info, component.value)\n"
+	code += "        if err != nil {\n"
+	code += "            return nil, err\n"
+	code += "        }\n"
+	code += "        key = append(key, byte(len(encoded)>>8), byte(len(encoded)))\n"
+	code += "        key = append(key, encoded...)\n"
+	code += "        key = append(key, 0)\n"
+	code += "    }\n"
+	code += "    return key, nil\n"
+	code += "}\n"
+	return code
+}
+
+// generateIterWrapper emits a typed iterator that scans rows straight into
+// the table struct. Queries must select the listed columns in order.
+func generateIterWrapper(structName string, columns []column, fields map[string]string) string {
+	iterName := structName + "Iter"
+
+	var names []string
+	var targets []string
+	for _, col := range columns {
+		names = append(names, col.Name)
+		targets = append(targets, "&row."+fields[col.Name])
+	}
+
+	code := fmt.Sprintf("// %sColumns lists the columns %s scans, in select order.\n", iterName, iterName)
+	code += fmt.Sprintf("var %sColumns = %s\n\n", iterName, goStringSlice(names))
+	code += fmt.Sprintf("// %s scans query results into %s values.\n", iterName, structName)
+	code += fmt.Sprintf("type %s struct {\n", iterName)
+	code += "    iter *gocql.Iter\n"
+	code += "}\n\n"
+	code += fmt.Sprintf("func New%s(iter *gocql.Iter) *%s {\n", iterName, iterName)
+	code += fmt.Sprintf("    return &%s{iter: iter}\n", iterName)
+	code += "}\n\n"
+	code += "// Next scans the next row into row, returning false when there are no\n"
+	code += "// more rows or scanning failed.\n"
+	code += fmt.Sprintf("func (it *%s) Next(row *%s) bool {\n", iterName, structName)
+	code += fmt.Sprintf("    return it.iter.Scan(%s)\n", strings.Join(targets, ", "))
+	code += "}\n\n"
+	code += "// Close closes the underlying iterator and returns any query error.\n"
+	code += fmt.Sprintf("func (it *%s) Close() error {\n", iterName)
+	code += "    return it.iter.Close()\n"
+	code += "}\n"
+	return code
+}
+
+// generateInsert emits an INSERT statement covering every column of a table
+// and a BindArgs method returning the field values in the statement's column
+// order. Both are built from the same column list, so they cannot drift
+// apart. Counter tables only accept UPDATE, so ok is false for them.
+func generateInsert(keyspace string, tableName string, structName string, columns []column, fields map[string]string) (string, bool) {
+	receiver := receiverName(structName)
+
+	var identifiers []string
+	var placeholders []string
+	var values []string
+	for _, col := range columns {
+		if strings.EqualFold(strings.TrimSpace(col.Type), "counter") {
+			return "", false
+		}
+		identifiers = append(identifiers, cqlIdentifier(col.Name))
+		placeholders = append(placeholders, "?")
+		values = append(values, receiver+"."+fields[col.Name])
+	}
+
+	statement := fmt.Sprintf("INSERT INTO %s.%s (%s) VALUES (%s)", cqlIdentifier(keyspace), cqlIdentifier(tableName), strings.Join(identifiers, ", "), strings.Join(placeholders, ", "))
+
+	code := fmt.Sprintf("// %sInsert inserts a full %s row; bind it with %s.BindArgs.\n", structName, structName, structName)
+	code += fmt.Sprintf("const %sInsert = %q\n\n", structName, statement)
+	code += fmt.Sprintf("// BindArgs returns the values of %s in %sInsert column order.\n", receiver, structName)
+	code += fmt.Sprintf("func (%s %s) BindArgs() []interface{} {\n", receiver, structName)
+	code += fmt.Sprintf("    return []interface{}{%s}\n", strings.Join(values, ", "))
+	code += "}\n"
+	return code, true
+}
+
+// batchTypes maps the -batchType values to the gocql batch types.
+var batchTypes = map[string]string{
+	"logged":   "gocql.LoggedBatch",
+	"unlogged": "gocql.UnloggedBatch",
+}
+
+func validateBatchType(batchType string) error {
+	if _, ok := batchTypes[batchType]; !ok {
+		return fmt.Errorf("unknown batch type %q: expected logged or unlogged", batchType)
+	}
+	return nil
+}
+
+// generateInsertBatch emits <Table>InsertBatch, which inserts a slice of rows
+// in one batch of the given type through the <Table>Insert statement, so the
+// column order matches it.
+func generateInsertBatch(structName string, batchType string) string {
+	name := structName + "InsertBatch"
+
+	code := fmt.Sprintf("// %s inserts rows in one %s batch of %sInsert statements.\n", name, batchType, structName)
+	code += "//\n"
+	code += "// A batch is sent to a single coordinator, which holds all of it in memory,\n"
+	code += "// and servers warn about or reject batches past their size thresholds\n"
+	code += "// (batch_size_warn_threshold_in_kb and batch_size_fail_threshold_in_kb).\n"
+	code += "// Keep batches small, ideally to rows of one partition, and split large\n"
+	code += "// loads into several batches or concurrent single inserts instead.\n"
+	code += fmt.Sprintf("func %s(ctx context.Context, session *gocql.Session, rows []%s) error {\n", name, structName)
+	code += "    if len(rows) == 0 {\n"
+	code += "        return nil\n"
+	code += "    }\n"
+	code += fmt.Sprintf("    batch := session.NewBatch(%s).WithContext(ctx)\n", batchTypes[batchType])
+	code += "    for _, row := range rows {\n"
+	code += fmt.Sprintf("        batch.Query(%sInsert, row.BindArgs()...)\n", structName)
+	code += "    }\n"
+	code += "    return session.ExecuteBatch(batch)\n"
+	code += "}\n"
+	return code
+}
+
+// generateRepo emits a <Table>Repo holding a session, with methods that
+// fetch and delete a row by its full primary key and, when insertable, insert
+// one through the <Table>Insert statement and BindArgs. columns must be in
+// struct field order.
+func generateRepo(keyspace string, tableName string, structName string, columns []column, fields map[string]string, mapper typeMapper, insertable bool) (string, error) {
+	repoName := structName + "Repo"
+	from := cqlIdentifier(keyspace) + "." + cqlIdentifier(tableName)
+
+	var keyColumns []column
+	for _, kind := range []string{"partition_key", "clustering"} {
+		var ofKind []column
+		for _, col := range columns {
+			if col.Kind == kind {
+				ofKind = append(ofKind, col)
+			}
+		}
+		sort.SliceStable(ofKind, func(i, j int) bool {
+			return ofKind[i].Position < ofKind[j].Position
+		})
+		keyColumns = append(keyColumns, ofKind...)
+	}
+
+	var conditions, params, args []string
+	for _, col := range keyColumns {
+		goType, err := mapper.cqlToGoType(col.Type)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		param := keyParamName(fields[col.Name])
+		conditions = append(conditions, cqlIdentifier(col.Name)+" = ?")
+		params = append(params, param+" "+goType)
+		args = append(args, param)
+	}
+	where := strings.Join(conditions, " AND ")
+
+	selectColumns, targets := repoSelectList(columns, fields)
+
+	keyArgs := ""
+	if len(args) > 0 {
+		keyArgs = ", " + strings.Join(args, ", ")
+	}
+
+	code := fmt.Sprintf("// %sSelectByKey selects the %s row with the given primary key.\n", structName, structName)
+	code += fmt.Sprintf("const %sSelectByKey = %q\n\n", structName, fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(selectColumns, ", "), from, where))
+	code += fmt.Sprintf("// %sDeleteByKey deletes the %s row with the given primary key.\n", structName, structName)
+	code += fmt.Sprintf("const %sDeleteByKey = %q\n\n", structName, fmt.Sprintf("DELETE FROM %s WHERE %s", from, where))
+
+	code += fmt.Sprintf("// %s runs %s queries against the %s table.\n", repoName, structName, tableName)
+	code += fmt.Sprintf("type %s struct {\n", repoName)
+	code += "    Session *gocql.Session\n"
+	code += "}\n\n"
+	code += fmt.Sprintf("func New%s(session *gocql.Session) *%s {\n", repoName, repoName)
+	code += fmt.Sprintf("    return &%s{Session: session}\n", repoName)
+	code += "}\n\n"
+
+	code += "// GetByKey returns the row with the given primary key, or gocql.ErrNotFound.\n"
+	code += fmt.Sprintf("func (r *%s) GetByKey(ctx context.Context, %s) (*%s, error) {\n", repoName, strings.Join(params, ", "), structName)
+	code += fmt.Sprintf("    var row %s\n", structName)
+	code += fmt.Sprintf("    if err := r.Session.Query(%sSelectByKey%s).WithContext(ctx).Scan(%s); err != nil {\n", structName, keyArgs, strings.Join(targets, ", "))
+	code += "        return nil, err\n"
+	code += "    }\n"
+	code += "    return &row, nil\n"
+	code += "}\n\n"
+
+	code += "// DeleteByKey deletes the row with the given primary key.\n"
+	code += fmt.Sprintf("func (r *%s) DeleteByKey(ctx context.Context, %s) error {\n", repoName, strings.Join(params, ", "))
+	code += fmt.Sprintf("    return r.Session.Query(%sDeleteByKey%s).WithContext(ctx).Exec()\n", structName, keyArgs)
+	code += "}\n"
+
+	if insertable {
+		code += "\n// Insert writes row, replacing any row with the same primary key.\n"
+		code += fmt.Sprintf("func (r *%s) Insert(ctx context.Context, row %s) error {\n", repoName, structName)
+		code += fmt.Sprintf("    return r.Session.Query(%sInsert, row.BindArgs()...).WithContext(ctx).Exec()\n", structName)
+		code += "}\n"
+	}
+
+	return code, nil
+}
+
+// repoSelectList returns the quoted column list repo queries select and the
+// matching Scan targets, fields of a variable named row.
+func repoSelectList(columns []column, fields map[string]string) ([]string, []string) {
+	var selectColumns, targets []string
+	for _, col := range columns {
+		selectColumns = append(selectColumns, cqlIdentifier(col.Name))
+		targets = append(targets, "&row."+fields[col.Name])
+	}
+	return selectColumns, targets
+}
+
+// keyParamName turns a struct field name into a parameter name that cannot
+// clash with Go keywords or the other names generated repo methods use.
+func keyParamName(field string) string {
+	name := scanVariableName(field)
+	if name == "ctx" || name == "r" || name == "row" || name == "rows" || name == "err" {
+		name += "Value"
+	}
+	return name
+}
+
+// parseIndexTarget splits an index target into the indexed column and how a
+// collection is indexed: "" for a plain column, or keys, values, entries or
+// full. Scylla's local index targets, JSON objects that also name the
+// partition key, are reported as not ok.
+func parseIndexTarget(target string) (string, string, bool) {
+	target = strings.TrimSpace(target)
+	if target == "" || strings.HasPrefix(target, "{") {
+		return "", "", false
+	}
+
+	mode := ""
+	for _, candidate := range []string{"keys", "values", "entries", "full"} {
+		if strings.HasPrefix(target, candidate+"(") && strings.HasSuffix(target, ")") {
+			mode = candidate
+			target = strings.TrimSpace(target[len(candidate)+1 : len(target)-1])
+			break
+		}
+	}
+
+	if len(target) >= 2 && strings.HasPrefix(target, `"`) && strings.HasSuffix(target, `"`) {
+		target = strings.ReplaceAll(target[1:len(target)-1], `""`, `"`)
+	}
+	return target, mode, target != ""
+}
+
+// generateIndexMethods emits, for each secondary index of a table, a select
+// statement and a <Table>Repo method returning every row whose indexed column
+// matches: GetBy<Field> for plain and full() indexes, GetBy<Field>Containing
+// for values() and GetBy<Field>ContainingKey for keys(). An index makes these
+// queries valid without ALLOW FILTERING. Local and entries() indexes, which
+// need more than one value to query, are skipped with a warning. columns must
+// be in struct field order.
+func generateIndexMethods(keyspace string, tableName string, structName string, columns []column, fields map[string]string, mapper typeMapper, indexes []indexSchema) (string, error) {
+	repoName := structName + "Repo"
+	from := cqlIdentifier(keyspace) + "." + cqlIdentifier(tableName)
+	selectColumns, targets := repoSelectList(columns, fields)
+
+	var methods []string
+	for _, index := range indexes {
+		columnName, mode, ok := parseIndexTarget(index.Target)
+		if !ok || mode == "entries" {
+			log.Printf("Warning: index %s on %s cannot be queried by one value; skipping its method", index.Name, tableName)
+			continue
+		}
+		col, ok := findColumn(columns, columnName)
+		if !ok {
+			log.Printf("Warning: index %s on %s targets column %s, which is not generated; skipping its method", index.Name, tableName, columnName)
+			continue
+		}
+
+		valueType := col.Type
+		operator, suffix := "= ?", ""
+		if mode == "values" || mode == "keys" {
+			collection, params, ok := parseParameterizedType(strings.TrimSpace(col.Type))
+			if !ok {
+				return "", fmt.Errorf("index %s: %s(%s) needs a collection column", index.Name, mode, col.Name)
+			}
+			collection = strings.ToLower(collection)
+
+			switch {
+			case mode == "keys" && collection == "map":
+				valueType, operator, suffix = params[0], "CONTAINS KEY ?", "ContainingKey"
+			case mode == "values" && collection == "map":
+				valueType, operator, suffix = params[1], "CONTAINS ?", "Containing"
+			case mode == "values" && (collection == "list" || collection == "set"):
+				valueType, operator, suffix = params[0], "CONTAINS ?", "Containing"
+			default:
+				return "", fmt.Errorf("index %s: %s(%s) does not apply to %s", index.Name, mode, col.Name, col.Type)
+			}
+		}
+
+		goType, err := mapper.cqlToGoType(valueType)
+		if err != nil {
+			return "", fmt.Errorf("index %s: %w", index.Name, err)
+		}
+
+		field := fields[col.Name]
+		method := "GetBy" + field + suffix
+		if method == "GetByKey" {
+			method = "GetByKeyColumn"
+		}
+		statement := structName + "SelectBy" + field + suffix
+		param := keyParamName(field)
+
+		code := fmt.Sprintf("// %s selects the %s rows matching %s through the %s index.\n", statement, structName, col.Name, index.Name)
+		code += fmt.Sprintf("const %s = %q\n\n", statement, fmt.Sprintf("SELECT %s FROM %s WHERE %s %s", strings.Join(selectColumns, ", "), from, cqlIdentifier(col.Name), operator))
+		code += fmt.Sprintf("// %s returns every row matching %s through the %s\n", method, col.Name, index.Name)
+		code += "// secondary index. Without a partition key the query visits every node, so\n"
+		code += "// it suits selective values rather than hot paths.\n"
+		code += fmt.Sprintf("func (r *%s) %s(ctx context.Context, %s %s) ([]%s, error) {\n", repoName, method, param, goType, structName)
+		code += fmt.Sprintf("    iter := r.Session.Query(%s, %s).WithContext(ctx).Iter()\n", statement, param)
+		code += fmt.Sprintf("    var rows []%s\n", structName)
+		code += "    for {\n"
+		code += fmt.Sprintf("        var row %s\n", structName)
+		code += fmt.Sprintf("        if !iter.Scan(%s) {\n", strings.Join(targets, ", "))
+		code += "            break\n"
+		code += "        }\n"
+		code += "        rows = append(rows, row)\n"
+		code += "    }\n"
+		code += "    return rows, iter.Close()\n"
+		code += "}\n"
+		methods = append(methods, code)
+	}
+
+	return strings.Join(methods, "\n"), nil
+}
+
+// generateAccessors emits a getter for each list, set and map field that
+// replaces a nil collection with an empty one before returning it, so the
+// result can always be written to. Scalar fields get no accessor.
+func generateAccessors(structName string, columns []column, fields map[string]string, mapper typeMapper) (string, error) {
+	receiver := receiverName(structName)
+	var accessors []string
+
+	for _, col := range columns {
+		node, err := mapper.parseCQLType(col.Type)
+		if err != nil || (node.Name != "list" && node.Name != "set" && node.Name != "map") {
+			continue
+		}
+
+		goType, err := mapper.cqlToGoType(col.Type)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", col.Name, err)
+		}
+
+		field := fields[col.Name]
+		code := fmt.Sprintf("// Get%s returns %s, initializing it first if it is nil.\n", field, field)
+		code += fmt.Sprintf("func (%s *%s) Get%s() %s {\n", receiver, structName, field, goType)
+		code += fmt.Sprintf("    if %s.%s == nil {\n", receiver, field)
+		code += fmt.Sprintf("        %s.%s = %s{}\n", receiver, field, goType)
+		code += "    }\n"
+		code += fmt.Sprintf("    return %s.%s\n", receiver, field)
+		code += "}\n"
+		accessors = append(accessors, code)
+	}
+
+	return strings.Join(accessors, "\n"), nil
+}
+
+// generateJSONMarshaler emits a MarshalJSON method that writes nil list, set
+// and map fields as empty JSON arrays and objects instead of null, by
+// marshaling a copy through a method-less alias type. It returns "" when the
+// struct has no collection fields. The method must not be generated for a
+// struct other structs embed, since it would be promoted to them.
+func generateJSONMarshaler(structName string, columns []column, fields map[string]string, mapper typeMapper) (string, error) {
+	receiver := receiverName(structName)
+	var checks string
+
+	for _, col := range columns {
+		node, err := mapper.parseCQLType(col.Type)
+		if err != nil || (node.Name != "list" && node.Name != "set" && node.Name != "map") {
+			continue
+		}
+
+		goType, err := mapper.cqlToGoType(col.Type)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", col.Name, err)
+		}
+
+		field := fields[col.Name]
+		checks += fmt.Sprintf("    if row.%s == nil {\n", field)
+		checks += fmt.Sprintf("        row.%s = %s{}\n", field, goType)
+		checks += "    }\n"
+	}
+
+	if checks == "" {
+		return "", nil
+	}
+
+	code := "// MarshalJSON implements json.Marshaler, writing nil collections as empty\n"
+	code += "// JSON arrays and objects rather than null.\n"
+	code += fmt.Sprintf("func (%s %s) MarshalJSON() ([]byte, error) {\n", receiver, structName)
+	code += fmt.Sprintf("    type plain %s\n", structName)
+	code += fmt.Sprintf("    row := plain(%s)\n", receiver)
+	code += checks
+	code += "    return json.Marshal(row)\n"
+	code += "}\n"
+	return code, nil
+}
+
+// generateConstructor emits New<Struct>, which returns a struct whose map and
+// set fields are empty rather than nil and whose columns listed in defaults
+// hold those values. Every other field keeps its zero value. fieldTypes maps
+// column names to the Go types of their fields.
+func generateConstructor(structName string, columns []column, fields map[string]string, fieldTypes map[string]string, defaults map[string]string) (string, error) {
+	receiver := receiverName(structName)
+
+	var assignments string
+	var defaulted []string
+	for _, col := range columns {
+		field, goType := fields[col.Name], fieldTypes[col.Name]
+
+		if value, ok := defaults[col.Name]; ok {
+			literal, err := defaultLiteral(col.Type, goType, value)
+			if err != nil {
+				return "", fmt.Errorf("column %s: %w", col.Name, err)
+			}
+			assignments += fmt.Sprintf("    %s.%s = %s\n", receiver, field, literal)
+			defaulted = append(defaulted, field)
+			continue
+		}
+
+		node, err := typeMapper{}.parseCQLType(col.Type)
+		if err == nil && (node.Name == "set" || node.Name == "map") {
+			assignments += fmt.Sprintf("    %s.%s = %s{}\n", receiver, field, goType)
+		}
+	}
+
+	code := fmt.Sprintf("// New%s returns a %s with empty, non-nil map and set fields.\n", structName, structName)
+	if len(defaulted) > 0 {
+		code += fmt.Sprintf("// It also sets the default values of %s.\n", strings.Join(defaulted, ", "))
+	}
+	code += fmt.Sprintf("func New%s() *%s {\n", structName, structName)
+	code += fmt.Sprintf("    %s := &%s{}\n", receiver, structName)
+	code += assignments
+	code += fmt.Sprintf("    return %s\n", receiver)
+	code += "}\n"
+
+	return code, nil
+}
+
+// defaultLiteral checks a -defaults value against the column's CQL type and
+// returns it as a Go literal. Only text, boolean, integer and floating-point
+// columns with a plain field type can have defaults.
+func defaultLiteral(cqlType string, goType string, value string) (string, error) {
+	if strings.HasPrefix(goType, "*") || strings.HasPrefix(goType, "sql.") {
+		return "", fmt.Errorf("-defaults needs a plain field, but the null typing makes it %s (keep it plain with -pointerColumns !table.column)", goType)
+	}
+
+	node, err := typeMapper{}.parseCQLType(cqlType)
+	if err != nil {
+		return "", err
+	}
+
+	invalid := func(err error) error {
+		return fmt.Errorf("invalid default %q for %s: %w", value, node.Name, err)
+	}
+
+	switch node.Name {
+	case "text", "varchar":
+		return strconv.Quote(value), nil
+
+	case "boolean":
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", invalid(err)
+		}
+		return strconv.FormatBool(parsed), nil
+
+	case "tinyint", "smallint", "int", "bigint", "counter":
+		bits := map[string]int{"tinyint": 8, "smallint": 16, "int": 32, "bigint": 64, "counter": 64}[node.Name]
+		parsed, err := strconv.ParseInt(value, 10, bits)
+		if err != nil {
+			return "", invalid(err)
+		}
+		return strconv.FormatInt(parsed, 10), nil
+
+	case "float", "double":
+		bits := 64
+		if node.Name == "float" {
+			bits = 32
+		}
+		parsed, err := strconv.ParseFloat(value, bits)
+		if err != nil {
+			return "", invalid(err)
+		}
+		if math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+			return "", invalid(fmt.Errorf("not a finite number"))
+		}
+		return strconv.FormatFloat(parsed, 'g', -1, bits), nil
+	}
+
+	return "", fmt.Errorf("-defaults supports text, boolean, integer and floating-point columns, not %s", cqlType)
+}
+
+// receiverName returns the method receiver name used for a generated struct.
+func receiverName(structName string) string {
+	first, _ := utf8.DecodeRuneInString(structName)
+	return string(unicode.ToLower(first))
+}
+
+// generateRegistry emits a sorted list of every generated table name, in a
+// variable whose name starts with prefix.
+func generateRegistry(prefix string, tables []tableSchema) string {
+	var names []string
+	for _, table := range tables {
+		names = append(names, table.Name)
+	}
+	sort.Strings(names)
+
+	return fmt.Sprintf("var %sAllTables = %s\n", prefix, goStringSlice(names))
+}
+
+// generateFingerprint emits a constant holding schemaFingerprint of the
+// keyspace, with a comment giving the algorithm so applications can compute
+// the same value from system_schema at runtime.
+func generateFingerprint(prefix string, schema keyspaceSchema) string {
+	code := fmt.Sprintf("// %sSchemaFingerprint identifies the %s schema these types were generated\n", prefix, schema.Name)
+	code += "// from; a changed table, column or type changes it. It is the hex SHA-256\n"
+	code += "// of these lines, sorted and joined by newlines, one per column and one per\n"
+	code += "// user-defined type:\n"
+	code += "//\n"
+	code += "//\tfmt.Sprintf(\"column %q %q %q %q %d %q\", table, column, type, kind, position, clusteringOrder)\n"
+	code += "//\tfmt.Sprintf(\"type %q\", name) + fmt.Sprintf(\" %q %q\", field, type)...\n"
+	code += "//\n"
+	code += "// The values are those of system_schema.columns and system_schema.types,\n"
+	code += "// except that every type is respelled with lowercase names, \", \" between\n"
+	code += "// parameters and user-defined type names quoted only when CQL needs it, so\n"
+	code += "// map<text,int> and map<text, int> hash alike.\n"
+	code += fmt.Sprintf("const %sSchemaFingerprint = %q\n", prefix, schemaFingerprint(schema))
+	return code
+}
+
+// schemaFingerprint hashes the shape of a keyspace: its tables, their columns
+// with type, kind, position and clustering order, and its user-defined types.
+// Types are hashed in the canonicalCQLType spelling, so the spacing and case
+// differences between server versions do not change the result. The lines
+// are sorted, so neither does the order the server returned tables, columns
+// and types in. UDT fields keep their order, which is part of the type.
+// Comments and enum values are left out. generateFingerprint documents the
+// algorithm in the generated code; keep the two in step.
+func schemaFingerprint(schema keyspaceSchema) string {
+	mapper := typeMapper{}.withUserTypes(schema.Types, "")
+
+	var lines []string
+	for _, table := range schema.Tables {
+		for _, col := range table.Columns {
+			lines = append(lines, fmt.Sprintf("column %q %q %q %q %d %q", table.Name, col.Name, mapper.canonicalCQLType(col.Type), col.Kind, col.Position, col.ClusteringOrder))
+		}
+	}
+	for _, udt := range schema.Types {
+		line := fmt.Sprintf("type %q", udt.Name)
+		for _, field := range udt.Fields {
+			line += fmt.Sprintf(" %q %q", field.Name, mapper.canonicalCQLType(field.Type))
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateGocqlxHelpers emits gocqlx table metadata for a table along with a
+// select-all builder whose column list follows the struct field order.
+// Metadata keeps raw column names because gocqlx binds struct fields by
+// them; identifiers that need quoting are quoted in the table name and in
+// the select builder's column list.
+func generateGocqlxHelpers(keyspace string, tableName string, name string, columns []column) string {
+	var selectColumns []string
+	needsQuoting := false
+	for _, col := range columns {
+		selectColumns = append(selectColumns, cqlIdentifier(col.Name))
+		if cqlIdentifier(col.Name) != col.Name {
+			needsQuoting = true
+		}
+	}
+
+	helpers := fmt.Sprintf("var %sMetadata = table.Metadata{\n", name)
+	helpers += fmt.Sprintf("    Name:    %q,\n", cqlIdentifier(keyspace)+"."+cqlIdentifier(tableName))
+	helpers += fmt.Sprintf("    Columns: %s,\n", goStringSlice(columnNames(columns)))
+	helpers += fmt.Sprintf("    PartKey: %s,\n", goStringSlice(columnsOfKind(columns, "partition_key")))
+	helpers += fmt.Sprintf("    SortKey: %s,\n", goStringSlice(columnsOfKind(columns, "clustering")))
+	helpers += "}\n\n"
+
+	// table.Metadata only names the sort key columns, so their order is
+	// exposed separately for building range queries.
+	if len(columnsOfKind(columns, "clustering")) > 0 {
+		helpers += fmt.Sprintf("var %sClusteringOrder = map[string]qb.Order{\n", name)
+		for _, col := range columns {
+			if col.Kind == "clustering" {
+				helpers += fmt.Sprintf("    %q: qb.%s,\n", col.Name, clusteringOrder(col))
+			}
+		}
+		helpers += "}\n\n"
+	}
+
+	helpers += fmt.Sprintf("var %sTable = table.New(%sMetadata)\n\n", name, name)
+	helpers += fmt.Sprintf("func Select%s() *qb.SelectBuilder {\n", name)
+	if needsQuoting {
+		helpers += fmt.Sprintf("    return qb.Select(%sMetadata.Name).Columns(%s...)\n", name, goStringSlice(selectColumns))
+	} else {
+		helpers += fmt.Sprintf("    return qb.Select(%sMetadata.Name).Columns(%sMetadata.Columns...)\n", name, name)
+	}
+	helpers += "}\n"
+	return helpers
+}
+
+func goStringSlice(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+	return "[]string{" + strings.Join(quoted, ", ") + "}"
+}
+
+// defaultGocqlImport is the import path used for gocql types unless
+// overridden, e.g. by a fork such as ScyllaDB's shard-aware driver.
+const defaultGocqlImport = "github.com/gocql/gocql"
+
+// generatedImports maps the package names generated code may refer to,
+// other than gocql, to their import paths.
+var generatedImports = map[string]string{
+	"big":     "math/big",
+	"binary":  "encoding/binary",
+	"context": "context",
+	"fmt":     "fmt",
+	"inf":     "gopkg.in/inf.v0",
+	"json":    "encoding/json",
+	"net":     "net",
+	"sql":     "database/sql",
+	"time":    "time",
+}
+
+// generateImports returns the import block required by the generated code.
+// Packages are imported when the code refers to them in a qualified
+// identifier such as time.Time; names in comments and string literals, which
+// hold table comments and CQL statements, do not count. Type references are
+// always qualified with "gocql", so a gocql import path whose last element is
+// not gocql is imported under that name. With gocqlx, the qb and table
+// packages are imported the same way, so a -splitFiles file without helpers
+// imports neither.
+func generateImports(code string, gocqlx bool, gocqlImport string) (string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package generated\n\n"+code, parser.SkipObjectResolution)
+	if err != nil {
+		return "", err
+	}
+
+	used := make(map[string]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		if selector, ok := node.(*ast.SelectorExpr); ok {
+			if ident, ok := selector.X.(*ast.Ident); ok {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	var imports []string
+	aliases := make(map[string]string)
+
+	if used["gocql"] {
+		imports = append(imports, gocqlImport)
+		if path.Base(gocqlImport) != "gocql" {
+			aliases[gocqlImport] = "gocql"
+		}
+	}
+	if gocqlx {
+		for _, name := range []string{"qb", "table"} {
+			if used[name] {
+				imports = append(imports, "github.com/scylladb/gocqlx/v2/"+name)
+			}
+		}
+	}
+	for name, importPath := range generatedImports {
+		if used[name] {
+			imports = append(imports, importPath)
+		}
+	}
+
+	if len(imports) == 0 {
+		return "", nil
+	}
+
+	sort.Strings(imports)
+
+	block := "import (\n"
+	for _, importPath := range imports {
+		if alias, ok := aliases[importPath]; ok {
+			block += fmt.Sprintf("    %s %q\n", alias, importPath)
+		} else {
+			block += fmt.Sprintf("    %q\n", importPath)
+		}
+	}
+	block += ")\n"
+	return block, nil
+}
+
+func toPascal(value string) string {
+	return exportedName(strcase.ToCamel(value))
+}
@@ -0,0 +1,639 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerateImports(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		gocqlx bool
+		want   []string
+	}{
+		{
+			name: "qualified identifiers",
+			code: "type T struct {\n    At time.Time\n    N *big.Int\n    D *inf.Dec\n    ID gocql.UUID\n}\n",
+			want: []string{defaultGocqlImport, "gopkg.in/inf.v0", "math/big", "time"},
+		},
+		{
+			name: "keyspace named like a package in a statement",
+			code: "const BigUsersInsert = \"INSERT INTO big.users (id) VALUES (?)\"\n",
+			want: nil,
+		},
+		{
+			name: "package names in comments",
+			code: "// Users: see fmt.Println docs, time.Time and json.Marshal(x).\ntype Users struct {\n    ID string\n}\n",
+			want: nil,
+		},
+		{
+			name: "method bodies",
+			code: "func (u Users) MarshalJSON() ([]byte, error) {\n    return json.Marshal(u)\n}\n\nfunc get(ctx context.Context) error {\n    return fmt.Errorf(\"x\")\n}\n",
+			want: []string{"context", "encoding/json", "fmt"},
+		},
+		{
+			name:   "gocqlx helpers",
+			code:   "var UsersMetadata = table.Metadata{Name: \"app.users\"}\n\nfunc SelectUsers() *qb.SelectBuilder {\n    return qb.Select(UsersMetadata.Name)\n}\n",
+			gocqlx: true,
+			want:   []string{"github.com/scylladb/gocqlx/v2/qb", "github.com/scylladb/gocqlx/v2/table"},
+		},
+		{
+			name:   "gocqlx file without helpers",
+			code:   "type Address struct {\n    ID gocql.UUID\n}\n",
+			gocqlx: true,
+			want:   []string{defaultGocqlImport},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, err := generateImports(tt.code, tt.gocqlx, defaultGocqlImport)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, match := range regexp.MustCompile(`"([^"]+)"`).FindAllStringSubmatch(block, -1) {
+				got = append(got, match[1])
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("imports = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateImportsAliasesGocqlFork(t *testing.T) {
+	block, err := generateImports("var ID gocql.UUID\n", false, "github.com/scylladb/gocql-fork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(block, `gocql "github.com/scylladb/gocql-fork"`) {
+		t.Errorf("import block %q does not alias the fork as gocql", block)
+	}
+}
+
+func TestGenerateInsertBindArgsOrder(t *testing.T) {
+	columns := []column{
+		{Name: "user_id", Type: "uuid", Kind: "partition_key"},
+		{Name: "created_at", Type: "timestamp", Kind: "clustering"},
+		{Name: "Email", Type: "text", Kind: "regular"},
+		{Name: "select", Type: "text", Kind: "regular"},
+		{Name: "tags", Type: "set<text>", Kind: "regular"},
+	}
+	fields := uniqueFieldNames(columnNames(columns), nil)
+
+	code, ok := generateInsert("big", "users", "Users", columns, fields)
+	if !ok {
+		t.Fatal("generateInsert reported the table as not insertable")
+	}
+
+	statementMatch := regexp.MustCompile(`const UsersInsert = (".*")`).FindStringSubmatch(code)
+	argsMatch := regexp.MustCompile(`return \[\]interface\{\}\{(.*)\}`).FindStringSubmatch(code)
+	if statementMatch == nil || argsMatch == nil {
+		t.Fatalf("no insert statement or BindArgs in:\n%s", code)
+	}
+
+	statement, err := strconv.Unquote(statementMatch[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	open, end := strings.Index(statement, "("), strings.Index(statement, ")")
+	var statementColumns []string
+	for _, identifier := range strings.Split(statement[open+1:end], ", ") {
+		if unquoted, err := strconv.Unquote(identifier); err == nil {
+			identifier = unquoted
+		}
+		statementColumns = append(statementColumns, identifier)
+	}
+
+	columnOfField := make(map[string]string)
+	for columnName, field := range fields {
+		columnOfField["u."+field] = columnName
+	}
+	var argColumns []string
+	for _, arg := range strings.Split(argsMatch[1], ", ") {
+		argColumns = append(argColumns, columnOfField[arg])
+	}
+
+	if want := columnNames(columns); !slices.Equal(statementColumns, want) {
+		t.Errorf("INSERT columns = %q, want %q", statementColumns, want)
+	}
+	if !slices.Equal(argColumns, statementColumns) {
+		t.Errorf("BindArgs columns = %q, INSERT columns = %q", argColumns, statementColumns)
+	}
+}
+
+func TestGenerateInsertRejectsCounters(t *testing.T) {
+	columns := []column{
+		{Name: "page", Type: "text", Kind: "partition_key"},
+		{Name: "views", Type: "counter", Kind: "regular"},
+	}
+	if _, ok := generateInsert("app", "page_views", "PageViews", columns, uniqueFieldNames(columnNames(columns), nil)); ok {
+		t.Error("generateInsert accepted a counter table")
+	}
+}
+
+func TestGenerateKeyspaceFileInsertKeyspaceNamedLikePackage(t *testing.T) {
+	schema := keyspaceSchema{
+		Name: "big",
+		Tables: []tableSchema{{
+			Name: "users",
+			Columns: []column{
+				{Name: "id", Type: "text", Kind: "partition_key"},
+				{Name: "name", Type: "text", Kind: "regular"},
+			},
+		}},
+	}
+
+	content, err := generateKeyspaceFile(schema, testGenerateOptions(func(opts *generateOptions) { opts.Insert = true }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), `"math/big"`) {
+		t.Errorf("keyspace big imports math/big:\n%s", content)
+	}
+}
+
+// testGenerateOptions returns the generate options of a run with default
+// flags, changed by each of configure.
+func testGenerateOptions(configure ...func(*generateOptions)) generateOptions {
+	opts, err := Options{Selection: SchemaFlags{Keyspaces: "test"}}.withDefaults().generateOptions()
+	if err != nil {
+		panic(err)
+	}
+	for _, change := range configure {
+		change(&opts)
+	}
+	return opts
+}
+
+func TestDocComments(t *testing.T) {
+	schema := keyspaceSchema{
+		Name: "app",
+		Tables: []tableSchema{
+			{
+				Name:    "users",
+				Comment: "See fmt.Println docs. Users are created on sign-up and never deleted, only deactivated by support staff.",
+				Columns: []column{{Name: "id", Type: "text", Kind: "partition_key"}},
+			},
+			{
+				Name:    "sessions",
+				Columns: []column{{Name: "id", Type: "text", Kind: "partition_key"}},
+			},
+		},
+	}
+
+	content, err := generateKeyspaceFile(schema, testGenerateOptions(func(opts *generateOptions) { opts.DocComments = true }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := string(content)
+
+	for _, want := range []string{
+		"// See fmt.Println docs. Users are created on sign-up and never deleted,\n// only deactivated by support staff.\ntype Users struct",
+		"// Sessions maps the sessions table.\ntype Sessions struct",
+	} {
+		if !strings.Contains(source, want) {
+			t.Errorf("generated file lacks %q:\n%s", want, source)
+		}
+	}
+	if strings.Contains(source, `"fmt"`) {
+		t.Errorf("a table comment naming fmt imports it:\n%s", source)
+	}
+}
+
+func TestAddressColumnsImportNet(t *testing.T) {
+	schema := keyspaceSchema{
+		Name: "app",
+		Tables: []tableSchema{{
+			Name: "hosts",
+			Columns: []column{
+				{Name: "name", Type: "ascii", Kind: "partition_key"},
+				{Name: "address", Type: "inet", Kind: "regular"},
+			},
+		}},
+	}
+
+	content, err := generateKeyspaceFile(schema, testGenerateOptions(func(opts *generateOptions) { opts.Struct.NullType = "pointers" }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := string(content)
+	for _, want := range []string{`"net"`, "Name    string", "Address net.IP"} {
+		if !strings.Contains(source, want) {
+			t.Errorf("generated file lacks %q:\n%s", want, source)
+		}
+	}
+}
+
+func TestEnumConstantsForASCIIColumn(t *testing.T) {
+	schema := keyspaceSchema{
+		Name: "app",
+		Tables: []tableSchema{{
+			Name: "orders",
+			Columns: []column{
+				{Name: "id", Type: "uuid", Kind: "partition_key"},
+				{Name: "status", Type: "ascii", Kind: "regular"},
+			},
+			EnumValues: map[string][]string{"status": {"in-progress", "shipped"}},
+		}},
+	}
+
+	content, err := generateKeyspaceFile(schema, testGenerateOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := string(content)
+	for _, want := range []string{"Status string", `OrdersStatusInProgress = "in-progress"`, `OrdersStatusShipped    = "shipped"`} {
+		if !strings.Contains(source, want) {
+			t.Errorf("generated file lacks %q:\n%s", want, source)
+		}
+	}
+}
+
+func TestTimeUUIDColumn(t *testing.T) {
+	// system_schema.columns.type holds "timeuuid"; older servers report
+	// the marshaller class name instead.
+	for _, cqlType := range []string{"timeuuid", normalizeFetchedType("column events.id", "org.apache.cassandra.db.marshal.TimeUUIDType")} {
+		col := column{Name: "event_id", Type: cqlType, Kind: "clustering"}
+
+		goType, err := typeMapper{IntType: "exact"}.cqlToGoType(col.Type)
+		if err != nil || goType != "gocql.UUID" {
+			t.Errorf("cqlToGoType(%q) = %q, %v; want gocql.UUID", col.Type, goType, err)
+		}
+
+		code, err := generateGoStruct("Events", []column{col}, structOptions{JSONCase: "column", TypeComments: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "EventId gocql.UUID `json:\"event_id\"` // timeuuid, time-based (version 1) UUID\n"; !strings.Contains(code, want) {
+			t.Errorf("struct for %q lacks %q:\n%s", cqlType, want, code)
+		}
+	}
+}
+
+func TestTimeOfDayColumn(t *testing.T) {
+	// system_schema.columns.type holds "time" for CQL time columns.
+	col := column{Name: "opens_at", Type: "time", Kind: "regular"}
+
+	goType, err := typeMapper{IntType: "exact"}.cqlToGoType(col.Type)
+	if err != nil || goType != "time.Duration" {
+		t.Errorf("cqlToGoType(time) = %q, %v; want time.Duration", goType, err)
+	}
+
+	tests := []struct {
+		name string
+		opts structOptions
+		want string
+	}{
+		{"plain", structOptions{JSONCase: "column"}, "OpensAt time.Duration `json:\"opens_at\"` // time of day as nanoseconds since midnight, not a duration\n"},
+		{"typeComments", structOptions{JSONCase: "column", TypeComments: true}, "OpensAt time.Duration `json:\"opens_at\"` // time, time of day as nanoseconds since midnight, not a duration\n"},
+		{"pointers", structOptions{JSONCase: "column", NullType: "pointers"}, "OpensAt *time.Duration `json:\"opens_at\"` // time of day as nanoseconds since midnight, not a duration\n"},
+	}
+	for _, tt := range tests {
+		code, err := generateGoStruct("Shops", []column{col}, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(code, tt.want) {
+			t.Errorf("%s: struct lacks %q:\n%s", tt.name, tt.want, code)
+		}
+	}
+
+	// A duration column is an interval and gets no time-of-day note.
+	code, err := generateGoStruct("Shops", []column{{Name: "slot", Type: "duration", Kind: "regular"}}, structOptions{JSONCase: "column"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(code, timeOfDayNote) {
+		t.Errorf("duration field has the time-of-day note:\n%s", code)
+	}
+}
+
+func TestFieldTypePointerColumns(t *testing.T) {
+	overrides, err := parsePointerColumns("users.name,!users.age,users.id,users.at,users.tags,!users.photo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columns := map[string]column{
+		"id":    {Name: "id", Type: "uuid", Kind: "partition_key"},
+		"at":    {Name: "at", Type: "timestamp", Kind: "clustering"},
+		"name":  {Name: "name", Type: "text", Kind: "regular"},
+		"age":   {Name: "age", Type: "int", Kind: "regular"},
+		"email": {Name: "email", Type: "text", Kind: "regular"},
+		"tags":  {Name: "tags", Type: "set<text>", Kind: "regular"},
+		"photo": {Name: "photo", Type: "blob", Kind: "regular"},
+	}
+
+	tests := []struct {
+		nullType string
+		column   string
+		want     string
+	}{
+		// Key columns are never null, so overrides leave them alone.
+		{"none", "id", "gocql.UUID"},
+		{"pointers", "id", "gocql.UUID"},
+		{"pointers", "at", "time.Time"},
+		{"sql", "at", "time.Time"},
+
+		// table.column forces a pointer whatever -nullType says.
+		{"none", "name", "*string"},
+		{"pointers", "name", "*string"},
+		{"sql", "name", "*string"},
+
+		// !table.column forces the plain type.
+		{"none", "age", "int"},
+		{"pointers", "age", "int"},
+		{"sql", "age", "int"},
+
+		// Columns without an override follow -nullType.
+		{"none", "email", "string"},
+		{"pointers", "email", "*string"},
+		{"sql", "email", "sql.NullString"},
+
+		// Nilable types stay plain either way.
+		{"none", "tags", "[]string"},
+		{"pointers", "photo", "[]byte"},
+	}
+
+	for _, tt := range tests {
+		opts := structOptions{NullType: tt.nullType, PointerColumns: overrides["users"]}
+		got, err := opts.fieldType(columns[tt.column])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("-nullType %s: %s is %s, want %s", tt.nullType, tt.column, got, tt.want)
+		}
+	}
+}
+
+func TestPointerColumnsOfEmbeddedColumns(t *testing.T) {
+	audit := []column{
+		{Name: "created_by", Type: "text", Kind: "regular"},
+		{Name: "updated_at", Type: "timestamp", Kind: "regular"},
+	}
+	schema := keyspaceSchema{
+		Name: "app",
+		Tables: []tableSchema{
+			{Name: "users", Columns: append([]column{{Name: "id", Type: "uuid", Kind: "partition_key"}}, audit...)},
+			{Name: "orders", Columns: append([]column{{Name: "id", Type: "uuid", Kind: "partition_key"}}, audit...)},
+		},
+	}
+
+	overrides, err := parsePointerColumns("!orders.updated_at")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := generateKeyspaceFile(schema, testGenerateOptions(func(opts *generateOptions) {
+		opts.Struct.NullType = "pointers"
+		opts.EmbedColumns = []string{"created_by", "updated_at"}
+		opts.EmbedName = "Audit"
+		opts.PointerColumns = overrides
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := string(content)
+
+	// users embeds the shared struct, typed by the global -nullType.
+	for _, want := range []string{
+		"type Audit struct {\n\tCreatedBy *string    `json:\"created_by\"`\n\tUpdatedAt *time.Time `json:\"updated_at\"`\n}",
+		"type Users struct {\n\tAudit\n\tId gocql.UUID `json:\"id\"`\n}",
+	} {
+		if !strings.Contains(source, want) {
+			t.Errorf("generated file lacks %q:\n%s", want, source)
+		}
+	}
+
+	// orders overrides a shared column, so it keeps its own fields.
+	if want := "type Orders struct {\n\tId        gocql.UUID `json:\"id\"`\n\tCreatedBy *string    `json:\"created_by\"`\n\tUpdatedAt time.Time  `json:\"updated_at\"`\n}"; !strings.Contains(source, want) {
+		t.Errorf("generated file lacks %q:\n%s", want, source)
+	}
+}
+
+func TestPointersAndOmitEmptyOnCollections(t *testing.T) {
+	columns := []column{
+		{Name: "id", Type: "uuid", Kind: "partition_key"},
+		{Name: "name", Type: "text", Kind: "regular"},
+		{Name: "attrs", Type: "map<text, int>", Kind: "regular"},
+		{Name: "tags", Type: "set<text>", Kind: "regular"},
+		{Name: "events", Type: "list<timestamp>", Kind: "regular"},
+	}
+
+	source, err := generateGoStruct("Users", columns, structOptions{NullType: "pointers", OmitEmpty: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Collections are nil when empty, so they keep their plain type and
+	// still take omitempty; keys never do.
+	for _, want := range []string{
+		"Id gocql.UUID `json:\"id\"`",
+		"Name *string `json:\"name,omitempty\"`",
+		"Attrs map[string]int `json:\"attrs,omitempty\"`",
+		"Tags []string `json:\"tags,omitempty\"`",
+		"Events []time.Time `json:\"events,omitempty\"`",
+	} {
+		if !strings.Contains(source, want) {
+			t.Errorf("struct lacks %q:\n%s", want, source)
+		}
+	}
+	if strings.Contains(source, "*map") || strings.Contains(source, "*[]") {
+		t.Errorf("struct has a pointer to a collection:\n%s", source)
+	}
+}
+
+func TestJSONCaseTags(t *testing.T) {
+	tests := []struct {
+		jsonCase string
+		userID   string
+		created  string
+	}{
+		{"column", "user_id", "createdAt"},
+		{"camel", "userId", "createdAt"},
+		{"lower", "userid", "createdat"},
+		{"snake", "user_id", "created_at"},
+		{"upper_snake", "USER_ID", "CREATED_AT"},
+	}
+
+	columns := []column{
+		{Name: "user_id", Type: "uuid", Kind: "partition_key"},
+		{Name: "createdAt", Type: "timestamp", Kind: "regular"},
+	}
+	udt := udtSchema{Name: "event", Fields: []udtField{
+		{Name: "user_id", Type: "uuid"},
+		{Name: "createdAt", Type: "timestamp"},
+	}}
+
+	for _, tt := range tests {
+		opts := structOptions{DBTags: true, JSONCase: tt.jsonCase, Types: typeMapper{}.withUserTypes([]udtSchema{udt}, "")}
+
+		// Only the json tag follows -jsonCase; db and cql tags must keep
+		// the column name to map onto the schema.
+		table, err := generateGoStruct("Users", columns, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			fmt.Sprintf("`db:\"user_id\" json:\"%s\"`", tt.userID),
+			fmt.Sprintf("`db:\"createdAt\" json:\"%s\"`", tt.created),
+		} {
+			if !strings.Contains(table, want) {
+				t.Errorf("-jsonCase %s: table struct lacks %s:\n%s", tt.jsonCase, want, table)
+			}
+		}
+
+		udtStruct, err := generateUDTStruct(udt, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			fmt.Sprintf("`cql:\"user_id\" json:\"%s\"`", tt.userID),
+			fmt.Sprintf("`cql:\"createdAt\" json:\"%s\"`", tt.created),
+		} {
+			if !strings.Contains(udtStruct, want) {
+				t.Errorf("-jsonCase %s: UDT struct lacks %s:\n%s", tt.jsonCase, want, udtStruct)
+			}
+		}
+	}
+}
+
+func TestJSONTagNameUpperSnake(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"id", "ID"},
+		{"user_id", "USER_ID"},
+		{"createdAt", "CREATED_AT"},
+		{"UserName", "USER_NAME"},
+		{"userID", "USER_ID"},
+		{"last-login", "LAST_LOGIN"},
+		{"address_line2", "ADDRESS_LINE_2"},
+		{"ALREADY_UPPER", "ALREADY_UPPER"},
+	}
+
+	for _, tt := range tests {
+		if got := jsonTagName(tt.name, "upper_snake"); got != tt.want {
+			t.Errorf("jsonTagName(%q, upper_snake) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtraTagsUpperSnake(t *testing.T) {
+	tags, err := parseExtraTags("yaml=upper_snake")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := generateGoStruct("Users", []column{{Name: "user_id", Type: "uuid", Kind: "partition_key"}}, structOptions{ExtraTags: tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "`json:\"user_id\" yaml:\"USER_ID\"`"; !strings.Contains(source, want) {
+		t.Errorf("struct lacks %s:\n%s", want, source)
+	}
+}
+
+func TestFieldNameIsExported(t *testing.T) {
+	tests := []struct {
+		column string
+		want   string
+	}{
+		{"user_id", "UserId"},
+		{"_internal", "Internal"},
+		{"$x", "X"},
+		{"_1", "X1"},
+		{"1abc", "X1Abc"},
+	}
+
+	for _, tt := range tests {
+		got := fieldName(tt.column)
+		if got != tt.want {
+			t.Errorf("fieldName(%q) = %q, want %q", tt.column, got, tt.want)
+		}
+		if !token.IsIdentifier(got) || !token.IsExported(got) {
+			t.Errorf("fieldName(%q) = %q, which is not an exported identifier", tt.column, got)
+		}
+	}
+
+	for camel, want := range map[string]string{"": "X", "x": "X", "1abc": "X1abc", "Ok": "Ok"} {
+		if got := exportedName(camel); got != want {
+			t.Errorf("exportedName(%q) = %q, want %q", camel, got, want)
+		}
+	}
+
+	names := uniqueFieldNames([]string{"x", "$x", "_1"}, nil)
+	if names["x"] != "X" || names["$x"] != "X2" || names["_1"] != "X1" {
+		t.Errorf("uniqueFieldNames = %v, want x as X, $x as X2 and _1 as X1", names)
+	}
+}
+
+func TestReceiverName(t *testing.T) {
+	for structName, want := range map[string]string{"Orders": "o", "Ärger": "ä", "Οrders": "ο"} {
+		got := receiverName(structName)
+		if got != want {
+			t.Errorf("receiverName(%q) = %q, want %q", structName, got, want)
+		}
+		if !token.IsIdentifier(got) {
+			t.Errorf("receiverName(%q) = %q, which is not an identifier", structName, got)
+		}
+	}
+}
+
+func TestSchemaFingerprint(t *testing.T) {
+	cassandra := keyspaceSchema{
+		Name:  "shop",
+		Types: []udtSchema{{Name: "Address", Fields: []udtField{{Name: "zip", Type: "int"}, {Name: "lines", Type: "list<text>"}}}},
+		Tables: []tableSchema{
+			{Name: "orders", Columns: []column{
+				{Name: "id", Type: "uuid", Kind: "partition_key", Position: 0},
+				{Name: "items", Type: "map<text, frozen<list<int>>>", Kind: "regular", Position: -1},
+				{Name: "ship_to", Type: `frozen<"Address">`, Kind: "regular", Position: -1},
+			}},
+			{Name: "users", Columns: []column{{Name: "id", Type: "uuid", Kind: "partition_key", Position: 0}}},
+		},
+	}
+
+	// The same schema in the other server spelling, returned in another order.
+	scylla := keyspaceSchema{
+		Name:  "shop",
+		Types: []udtSchema{{Name: "Address", Fields: []udtField{{Name: "zip", Type: "int"}, {Name: "lines", Type: "list<text>"}}}},
+		Tables: []tableSchema{
+			{Name: "users", Columns: []column{{Name: "id", Type: "uuid", Kind: "partition_key", Position: 0}}},
+			{Name: "orders", Columns: []column{
+				{Name: "ship_to", Type: `frozen<"Address">`, Kind: "regular", Position: -1},
+				{Name: "items", Type: "map<text,frozen<list<int>>>", Kind: "regular", Position: -1},
+				{Name: "id", Type: "uuid", Kind: "partition_key", Position: 0},
+			}},
+		},
+	}
+
+	fingerprint := schemaFingerprint(cassandra)
+	if got := schemaFingerprint(scylla); got != fingerprint {
+		t.Errorf("the two spellings fingerprint as %s and %s", fingerprint, got)
+	}
+
+	changed := scylla
+	changed.Tables = append([]tableSchema{}, scylla.Tables...)
+	changed.Tables[1].Columns = append([]column{}, scylla.Tables[1].Columns...)
+	changed.Tables[1].Columns[1].Type = "map<text,list<int>>"
+	if schemaFingerprint(changed) == fingerprint {
+		t.Error("unfreezing a column type kept the fingerprint")
+	}
+
+	reordered := cassandra
+	reordered.Types = []udtSchema{{Name: "Address", Fields: []udtField{{Name: "lines", Type: "list<text>"}, {Name: "zip", Type: "int"}}}}
+	if schemaFingerprint(reordered) == fingerprint {
+		t.Error("reordering UDT fields kept the fingerprint")
+	}
+}
@@ -3,10 +3,13 @@ module github.com/ekremugur17/go-cql-scaffold
 go 1.22.5
 
 require (
-	github.com/gocql/gocql v1.7.0 // indirect
+	github.com/gocql/gocql v1.7.0
+	github.com/iancoleman/strcase v0.3.0
+	gopkg.in/inf.v0 v0.9.1
+)
+
+require (
 	github.com/golang/snappy v0.0.3 // indirect
 	github.com/hailocab/go-hostpool v0.0.0-20160125115350-e80d13ce29ed // indirect
-	github.com/iancoleman/strcase v0.3.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
-	gopkg.in/inf.v0 v0.9.1 // indirect
 )
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Versions required by the go.mod that -initModule writes. They match the
+// releases the generated code is written against.
+const (
+	gocqlVersion  = "v1.7.0"
+	gocqlxVersion = "v2.8.0"
+)
+
+// validateModulePath rejects module paths go.mod cannot hold unquoted.
+func validateModulePath(modulePath string) error {
+	if strings.ContainsAny(modulePath, " \t\r\n\"'`") || strings.HasPrefix(modulePath, "/") {
+		return fmt.Errorf("invalid -initModule path %q", modulePath)
+	}
+	return nil
+}
+
+// moduleFilePath returns where -initModule writes go.mod: the output
+// directory, or the directory of -outputFile when one is given.
+func (o Options) moduleFilePath() string {
+	if o.OutputFile != "" {
+		return filepath.Join(filepath.Dir(o.OutputFile), "go.mod")
+	}
+	return filepath.Join(o.OutputDir, "go.mod")
+}
+
+// moduleFile returns a go.mod declaring modulePath and requiring the driver
+// packages generated code imports. go.sum is left to go mod tidy.
+func moduleFile(modulePath string, gocqlImport string, gocqlx bool) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "module %s\n\ngo 1.22\n", modulePath)
+
+	var requires []string
+	if gocqlImport == defaultGocqlImport {
+		requires = append(requires, defaultGocqlImport+" "+gocqlVersion)
+	}
+	if gocqlx {
+		requires = append(requires, "github.com/scylladb/gocqlx/v2 "+gocqlxVersion)
+	}
+
+	if len(requires) > 0 {
+		b.WriteString("\nrequire (\n")
+		for _, require := range requires {
+			fmt.Fprintf(&b, "\t%s\n", require)
+		}
+		b.WriteString(")\n")
+	}
+
+	return []byte(b.String())
+}
+
+// writeModuleFile writes the -initModule go.mod unless a go.mod is already
+// there; an existing one is never touched, even with -force.
+func (o Options) writeModuleFile(modes outputModes) error {
+	modulePath := o.moduleFilePath()
+
+	_, err := os.Stat(modulePath)
+	if err == nil {
+		log.Printf("Keeping existing %s", modulePath)
+		return nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("checking %s: %w", modulePath, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(modulePath), modes.Dir); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+	if err := os.WriteFile(modulePath, moduleFile(o.InitModule, o.GocqlImport, o.Gocqlx), modes.File); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated %s\n", modulePath)
+	if o.GocqlImport != defaultGocqlImport {
+		log.Printf("Add a require for %s to %s; its version is not known here", o.GocqlImport, modulePath)
+	}
+	log.Printf("Run go mod tidy in %s to record checksums before building", filepath.Dir(modulePath))
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const hashCacheFileName = ".cqlscaffold.hash"
+
+// schemaHashCache records, per keyspace, a hash of the schema and of the
+// options that shape the output of the last successful generate run. Files
+// maps the path of every file that run generated, relative to the cache's
+// directory, to a hash of its content, so output deleted or edited since
+// does not count as up to date.
+type schemaHashCache struct {
+	Keyspaces map[string]string `json:"keyspaces"`
+	Files     map[string]string `json:"files"`
+}
+
+// hashCachePath returns where the cache lives: in the output directory, or
+// next to -outputFile when one is given.
+func (o Options) hashCachePath() string {
+	if o.OutputFile != "" {
+		return filepath.Join(filepath.Dir(o.OutputFile), hashCacheFileName)
+	}
+	return filepath.Join(o.OutputDir, hashCacheFileName)
+}
+
+// outputFingerprint encodes every option that changes what is generated, so
+// a changed format, tag setting, header or file permission invalidates the
+// cache.
+func (o Options) outputFingerprint(genOpts generateOptions) ([]byte, error) {
+	return json.Marshal(struct {
+		Formats      string
+		OutputFile   string
+		LineEnding   string
+		FileMode     string
+		DirMode      string
+		Enums        string
+		EnumLimit    int
+		ColumnOrders map[string][]string
+		Generate     generateOptions
+	}{o.Formats, o.OutputFile, o.LineEnding, o.FileMode, o.DirMode, o.Enums, o.EnumLimit, o.ColumnOrders, genOpts})
+}
+
+// hashSchemas returns the cache entry of every keyspace in schemas.
+func hashSchemas(schemas []keyspaceSchema, fingerprint []byte) (schemaHashCache, error) {
+	cache := schemaHashCache{Keyspaces: make(map[string]string, len(schemas))}
+
+	for _, schema := range schemas {
+		content, err := json.Marshal(schema)
+		if err != nil {
+			return cache, fmt.Errorf("hashing keyspace %s: %w", schema.Name, err)
+		}
+
+		sum := sha256.New()
+		sum.Write(fingerprint)
+		sum.Write(content)
+		cache.Keyspaces[schema.Name] = hex.EncodeToString(sum.Sum(nil))
+	}
+
+	return cache, nil
+}
+
+// loadHashCache reads the cache at path. A missing file is an empty cache.
+func loadHashCache(path string) (schemaHashCache, error) {
+	var cache schemaHashCache
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return cache, err
+	}
+
+	if err := json.Unmarshal(content, &cache); err != nil {
+		return cache, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return cache, nil
+}
+
+// matches reports whether c records exactly the keyspaces and hashes of
+// current.
+func (c schemaHashCache) matches(current schemaHashCache) bool {
+	if len(c.Keyspaces) != len(current.Keyspaces) {
+		return false
+	}
+	for keyspace, hash := range current.Keyspaces {
+		if c.Keyspaces[keyspace] != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// recordFiles sets c.Files to the content hashes of files.
+func (c *schemaHashCache) recordFiles(files []generatedFile, cacheDir string) error {
+	c.Files = make(map[string]string, len(files))
+	for _, file := range files {
+		relative, err := filepath.Rel(cacheDir, file.Path)
+		if err != nil {
+			return err
+		}
+		c.Files[filepath.ToSlash(relative)] = contentHash(file.Content)
+	}
+	return nil
+}
+
+// outputIntact reports whether every file c records is still on disk with
+// the content it was written with. A cache from before files were recorded
+// is never intact.
+func (c schemaHashCache) outputIntact(cacheDir string) bool {
+	if c.Files == nil {
+		return false
+	}
+	for relative, hash := range c.Files {
+		content, err := os.ReadFile(filepath.Join(cacheDir, filepath.FromSlash(relative)))
+		if err != nil || contentHash(content) != hash {
+			return false
+		}
+	}
+	return true
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func writeHashCache(path string, cache schemaHashCache, modes outputModes) error {
+	content, err := marshalJSON(cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), modes.Dir); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, modes.File)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashCacheOutputIntact(t *testing.T) {
+	dir := t.TempDir()
+	files := []generatedFile{
+		{Path: filepath.Join(dir, "shop", "models.go"), Content: []byte("package models\n")},
+		{Path: filepath.Join(dir, "shop", "orders.go"), Content: []byte("package models\n\ntype Orders struct{}\n")},
+	}
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file.Path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file.Path, file.Content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache := schemaHashCache{Keyspaces: map[string]string{"shop": "hash"}}
+	if err := cache.recordFiles(files, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeHashCache(filepath.Join(dir, hashCacheFileName), cache, outputModes{File: 0o644, Dir: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := loadHashCache(filepath.Join(dir, hashCacheFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cached.matches(cache) || !cached.outputIntact(dir) {
+		t.Fatal("a freshly written cache does not match its own output")
+	}
+
+	// Editing a file is a miss until it is written back.
+	if err := os.WriteFile(files[1].Path, []byte("package models\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if cached.outputIntact(dir) {
+		t.Error("an edited output file counts as intact")
+	}
+	if err := os.WriteFile(files[1].Path, files[1].Content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !cached.outputIntact(dir) {
+		t.Error("restoring the output file does not make it intact again")
+	}
+
+	if err := os.Remove(files[0].Path); err != nil {
+		t.Fatal(err)
+	}
+	if cached.outputIntact(dir) {
+		t.Error("a deleted output file counts as intact")
+	}
+
+	// A cache written before files were recorded cannot vouch for them.
+	if (schemaHashCache{Keyspaces: cache.Keyspaces}).outputIntact(dir) {
+		t.Error("a cache without files counts as intact")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+const defaultIgnoreFile = ".cqlscaffoldignore"
+
+// exclusions holds glob patterns for tables and columns to leave out of the
+// generated code. Patterns use path.Match syntax; column patterns are written
+// as "table.column" and match the table and column parts separately.
+type exclusions struct {
+	Tables  []string
+	Columns []string
+}
+
+// add records a pattern, treating it as a column pattern when it contains a
+// dot and as a table pattern otherwise.
+func (e *exclusions) add(pattern string) error {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return nil
+	}
+
+	tablePattern, columnPattern, isColumn := strings.Cut(pattern, ".")
+	for _, part := range []string{tablePattern, columnPattern} {
+		if _, err := path.Match(part, ""); err != nil {
+			return fmt.Errorf("invalid exclusion pattern %q: %w", pattern, err)
+		}
+	}
+
+	if isColumn {
+		e.Columns = append(e.Columns, pattern)
+	} else {
+		e.Tables = append(e.Tables, pattern)
+	}
+	return nil
+}
+
+func (e exclusions) excludesTable(table string) bool {
+	for _, pattern := range e.Tables {
+		if ok, _ := path.Match(pattern, table); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (e exclusions) excludesColumn(table string, columnName string) bool {
+	for _, pattern := range e.Columns {
+		tablePattern, columnPattern, _ := strings.Cut(pattern, ".")
+		tableMatch, _ := path.Match(tablePattern, table)
+		columnMatch, _ := path.Match(columnPattern, columnName)
+		if tableMatch && columnMatch {
+			return true
+		}
+	}
+	return false
+}
+
+// loadIgnoreFile adds the patterns listed in an ignore file, one per line.
+// Blank lines and lines starting with # are skipped. A missing file is only
+// an error when required is set, i.e. when the path was given explicitly.
+func (e *exclusions) loadIgnoreFile(filePath string, required bool) error {
+	file, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err := e.add(line); err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,137 @@
+package main
+
+import "fmt"
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// emitJSONSchema writes a JSON Schema document per table, with user-defined
+// types included under $defs. Primary key columns are required.
+func emitJSONSchema(schemas []keyspaceSchema, opts generateOptions) ([]generatedFile, error) {
+	var files []generatedFile
+
+	for _, schema := range schemas {
+		mapper := typeMapper{}.withUserTypes(schema.Types, "")
+		udts := make(map[string]udtSchema, len(schema.Types))
+		for _, udt := range schema.Types {
+			udts[udt.Name] = udt
+		}
+
+		for _, table := range schema.Tables {
+			defs := make(map[string]interface{})
+			properties := make(map[string]interface{})
+			var required []string
+
+			for _, col := range table.Columns {
+				property, err := cqlToJSONSchema(col.Type, mapper, udts, defs)
+				if err != nil {
+					return nil, fmt.Errorf("table %s column %s: %w", table.Name, col.Name, err)
+				}
+
+				properties[col.Name] = property
+				if isPrimaryKey(col) {
+					required = append(required, col.Name)
+				}
+			}
+
+			document := map[string]interface{}{
+				"$schema":    jsonSchemaDialect,
+				"$comment":   generatedMarker,
+				"title":      toPascal(table.Name),
+				"type":       "object",
+				"properties": properties,
+			}
+			if len(required) > 0 {
+				document["required"] = required
+			}
+			if len(defs) > 0 {
+				document["$defs"] = defs
+			}
+
+			content, err := marshalJSON(document)
+			if err != nil {
+				return nil, err
+			}
+
+			filePath := sanitizePathComponent(schema.Name) + "/" + sanitizePathComponent(table.Name) + ".schema.json"
+			files = append(files, generatedFile{Path: filePath, Content: content})
+		}
+	}
+
+	return files, nil
+}
+
+// cqlToJSONSchema maps a CQL type to a JSON Schema describing its JSON form.
+// User-defined types are added to defs and referenced by name.
+func cqlToJSONSchema(cqlType string, mapper typeMapper, udts map[string]udtSchema, defs map[string]interface{}) (map[string]interface{}, error) {
+	node, err := mapper.parseCQLType(cqlType)
+	if err != nil {
+		return nil, err
+	}
+	return jsonSchemaType(node, mapper, udts, defs)
+}
+
+func jsonSchemaType(node cqlTypeNode, mapper typeMapper, udts map[string]udtSchema, defs map[string]interface{}) (map[string]interface{}, error) {
+	switch {
+	case (node.Name == "list" || node.Name == "set") && len(node.Params) == 1:
+		items, err := jsonSchemaType(node.Params[0], mapper, udts, defs)
+		if err != nil {
+			return nil, err
+		}
+		schema := map[string]interface{}{"type": "array", "items": items}
+		if node.Name == "set" {
+			schema["uniqueItems"] = true
+		}
+		return schema, nil
+
+	case node.Name == "map" && len(node.Params) == 2:
+		values, err := jsonSchemaType(node.Params[1], mapper, udts, defs)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+
+	case len(node.Params) > 0:
+		return nil, fmt.Errorf("unknown CQL type: %s", node.Name)
+	}
+
+	if udt, ok := udts[node.UDT]; ok {
+		name := mapper.UDTs[udt.Name]
+		if _, done := defs[name]; !done {
+			// Reserve the name first so recursive references terminate.
+			defs[name] = nil
+			properties := make(map[string]interface{})
+			for _, field := range udt.Fields {
+				property, err := cqlToJSONSchema(field.Type, mapper, udts, defs)
+				if err != nil {
+					return nil, fmt.Errorf("type %s field %s: %w", udt.Name, field.Name, err)
+				}
+				properties[field.Name] = property
+			}
+			defs[name] = map[string]interface{}{"type": "object", "properties": properties}
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + name}, nil
+	}
+
+	switch node.Name {
+	case "boolean":
+		return map[string]interface{}{"type": "boolean"}, nil
+	case "tinyint", "smallint", "int", "bigint", "counter", "varint", "time":
+		return map[string]interface{}{"type": "integer"}, nil
+	case "float", "double", "decimal":
+		return map[string]interface{}{"type": "number"}, nil
+	case "text", "varchar", "ascii", "duration":
+		return map[string]interface{}{"type": "string"}, nil
+	case "uuid", "timeuuid":
+		return map[string]interface{}{"type": "string", "format": "uuid"}, nil
+	case "timestamp":
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	case "date":
+		return map[string]interface{}{"type": "string", "format": "date"}, nil
+	case "inet":
+		return map[string]interface{}{"type": "string"}, nil
+	case "blob":
+		return map[string]interface{}{"type": "string", "contentEncoding": "base64"}, nil
+	default:
+		return nil, fmt.Errorf("unknown CQL type: %s", node.Name)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// reservedKeywords are the CQL keywords that cannot be used as unquoted
+// identifiers.
+var reservedKeywords = map[string]bool{
+	"add": true, "allow": true, "alter": true, "and": true, "apply": true,
+	"asc": true, "authorize": true, "batch": true, "begin": true, "by": true,
+	"columnfamily": true, "create": true, "delete": true, "desc": true,
+	"describe": true, "drop": true, "entries": true, "execute": true,
+	"from": true, "full": true, "grant": true, "if": true, "in": true,
+	"index": true, "infinity": true, "insert": true, "into": true, "is": true,
+	"keyspace": true, "limit": true, "materialized": true, "modify": true,
+	"nan": true, "norecursive": true, "not": true, "null": true, "of": true,
+	"on": true, "or": true, "order": true, "primary": true, "rename": true,
+	"replace": true, "revoke": true, "schema": true, "select": true,
+	"set": true, "table": true, "to": true, "token": true, "truncate": true,
+	"unlogged": true, "update": true, "use": true, "using": true, "view": true,
+	"where": true, "with": true,
+}
+
+var unquotedIdentifier = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+func isReservedKeyword(name string) bool {
+	return reservedKeywords[strings.ToLower(name)]
+}
+
+// cqlIdentifier returns name as it must appear in a CQL statement: quoted
+// when it is a reserved keyword or would otherwise be case-folded.
+func cqlIdentifier(name string) string {
+	if unquotedIdentifier.MatchString(name) && !isReservedKeyword(name) {
+		return name
+	}
+	return quoteIdentifier(name)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+)
+
+// ANSI sequences used to color log lines.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+)
+
+// logLevelColors colors a log message by how it starts: problems the run
+// continues past are yellow and errors are red. Other messages, such as the
+// -verbose progress lines, keep the terminal's color.
+var logLevelColors = []struct {
+	prefix string
+	color  string
+}{
+	{"Warning:", ansiYellow},
+	{"Skipping", ansiYellow},
+	{"Skipped", ansiYellow},
+	{"Ignoring", ansiYellow},
+	{"Error", ansiRed},
+	{"Timed out", ansiRed},
+	{"Interrupted", ansiRed},
+}
+
+// colorLogWriter colors the lines the standard logger writes, dimming the
+// timestamp so the message stands out.
+type colorLogWriter struct {
+	out io.Writer
+}
+
+func (w colorLogWriter) Write(p []byte) (int, error) {
+	line := bytes.TrimSuffix(p, []byte("\n"))
+
+	// The default logger flags write "2006/01/02 15:04:05 ".
+	var timestamp []byte
+	if len(line) > 20 && line[19] == ' ' {
+		timestamp, line = line[:20], line[20:]
+	}
+
+	var b bytes.Buffer
+	if timestamp != nil {
+		b.WriteString(ansiDim)
+		b.Write(timestamp)
+		b.WriteString(ansiReset)
+	}
+	color := ""
+	for _, level := range logLevelColors {
+		if bytes.HasPrefix(line, []byte(level.prefix)) {
+			color = level.color
+			break
+		}
+	}
+	if color != "" {
+		b.WriteString(color)
+		b.Write(line)
+		b.WriteString(ansiReset)
+	} else {
+		b.Write(line)
+	}
+	b.WriteString("\n")
+
+	if _, err := w.out.Write(b.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// setupLogColor colors log output when stderr is a terminal, unless NO_COLOR
+// is set to any value (https://no-color.org) or TERM is dumb.
+func setupLogColor() {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return
+	}
+	info, err := os.Stderr.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return
+	}
+	log.SetOutput(colorLogWriter{out: os.Stderr})
+}
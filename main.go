@@ -1,240 +1,786 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime/debug"
+	"slices"
 	"strings"
-	"unicode"
+	"time"
 
 	"github.com/gocql/gocql"
-	"github.com/iancoleman/strcase"
 )
 
-func fetchTableNames(session *gocql.Session, keyspace string) ([]string, error) {
-	var tableName string
-	var tableNames []string
+// sanitizePathComponent makes a keyspace name safe to use as a file or
+// directory name by replacing characters that are illegal on common
+// filesystems. Queries and generated code keep using the original name.
+func sanitizePathComponent(name string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
 
-	query := fmt.Sprintf("SELECT table_name FROM system_schema.tables WHERE keyspace_name = '%s'", keyspace)
-	iter := session.Query(query).Iter()
+	if sanitized == "." || sanitized == ".." {
+		return strings.Repeat("_", len(sanitized))
+	}
+	return sanitized
+}
 
-	for iter.Scan(&tableName) {
-		tableNames = append(tableNames, tableName)
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
 
-	if err := iter.Close(); err != nil {
-		return nil, err
+// version is reported by the version command and can be set at build time
+// with -ldflags "-X main.version=...".
+var version = "dev"
+
+func main() {
+	setupLogColor()
+
+	args := os.Args[1:]
+
+	// Invocations that start with a flag predate subcommands and keep
+	// meaning generate.
+	command := "generate"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	return tableNames, nil
+	switch command {
+	case "generate":
+		runGenerate(args)
+	case "validate":
+		runValidate(args)
+	case "check":
+		runCheck(args)
+	case "version":
+		runVersion()
+	case "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		usage()
+		os.Exit(2)
+	}
 }
 
-func fetchColumnDefinitions(session *gocql.Session, keyspace string, tableName string) (map[string]string, error) {
-	query := fmt.Sprintf("SELECT column_name, type FROM system_schema.columns WHERE keyspace_name = '%s' AND table_name = '%s'", keyspace, tableName)
-	iter := session.Query(query).Iter()
+func usage() {
+	fmt.Fprint(os.Stderr, `Usage: go-cql-scaffold <command> [flags]
 
-	var columnName string
-	var columnType string
-	columns := make(map[string]string)
+Commands:
+  generate  Introspect keyspaces and generate Go code (default)
+  validate  Check connectivity and print schema info without writing files
+  check     Only connect, then print the cluster, datacenter and protocol
+  version   Print the tool version
 
-	for iter.Scan(&columnName, &columnType) {
-		columns[columnName] = columnType
-	}
+Run "go-cql-scaffold <command> -h" for the flags of a command.
+`)
+}
 
-	if err := iter.Close(); err != nil {
-		return nil, err
+func runVersion() {
+	if version == "dev" {
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			fmt.Println("go-cql-scaffold", info.Main.Version)
+			return
+		}
 	}
 
-	return columns, nil
+	fmt.Println("go-cql-scaffold", version)
 }
 
-func connectToScylla(host string, port int) (*gocql.Session, error) {
-	cluster := gocql.NewCluster(host)
-	cluster.Port = port
-	cluster.Consistency = gocql.Quorum
+// SchemaFlags selects which keyspaces, tables and columns are introspected.
+// Both generate and validate share them.
+type SchemaFlags struct {
+	// Keyspaces is a comma-separated list of keyspace names, and
+	// KeyspaceRegex also selects every non-system keyspace it matches.
+	Keyspaces     string
+	KeyspaceRegex string
+
+	// Table, when set, limits the run to that one table.
+	Table string
+
+	// Verbose logs connection and progress details.
+	Verbose bool
+
+	// IgnoreFile is a file of table and table.column patterns to skip.
+	// ExcludeTables and ExcludeColumns are comma-separated lists of the
+	// same patterns; a bare column name matches every table.
+	IgnoreFile     string
+	ExcludeTables  string
+	ExcludeColumns string
+}
 
-	session, err := cluster.CreateSession()
-	if err != nil {
-		return nil, err
-	}
+func addConnectionFlags(fs *flag.FlagSet, opts *ConnectionOptions) {
+	fs.StringVar(&opts.Host, "host", "localhost", "ScyllaDB host address")
+	fs.IntVar(&opts.Port, "port", 9042, "ScyllaDB port")
+	fs.StringVar(&opts.BindAddr, "bindAddr", "", "Local source IP address to bind outgoing connections to")
+	fs.IntVar(&opts.NumConns, "numConns", 0, "Number of connections per host (defaults to gocql's default when unset)")
+	fs.IntVar(&opts.QueryRetries, "queryRetries", 0, "Number of times to retry a failed schema query")
+	fs.StringVar(&opts.SerialConsistency, "serialConsistency", "", "Serial consistency of conditional (IF) statements: serial or local_serial (defaults to gocql's default when unset)")
+	fs.StringVar(&opts.Username, "username", "", "Username for password authentication")
+	fs.StringVar(&opts.Password, "password", "", "Password for password authentication")
+	fs.StringVar(&opts.AllowedAuthenticators, "allowedAuthenticators", "", "Comma-separated server authenticator class names password authentication accepts, replacing gocql's defaults (list org.apache.cassandra.auth.PasswordAuthenticator too if it is still needed)")
+	fs.StringVar(&opts.Cqlshrc, "cqlshrc", "", "cqlshrc file whose [connection] and [authentication] sections default unset flags (default ~/.cassandra/cqlshrc when present)")
+	fs.BoolVar(&opts.TLS, "tls", false, "Connect using TLS")
+	fs.StringVar(&opts.TLSCA, "tlsCA", "", "CA certificate file for verifying the cluster (implies -tls)")
+	fs.StringVar(&opts.TLSCert, "tlsCert", "", "Client certificate file (implies -tls)")
+	fs.StringVar(&opts.TLSKey, "tlsKey", "", "Client private key file (implies -tls)")
+	fs.BoolVar(&opts.TLSSkipVerify, "tlsSkipVerify", false, "Do not verify the cluster's certificate and host name")
+	fs.StringVar(&opts.Config, "config", "", "Config file holding connection profiles (default ./"+defaultConfigFile+")")
+	fs.StringVar(&opts.Profile, "profile", "", "Connection profile from the config file; explicit flags override it")
+	fs.DurationVar(&opts.Timeout, "timeout", 0, "Abort the whole run after this long, e.g. 2m (0 means no limit)")
+}
 
-	return session, nil
+func addSchemaFlags(fs *flag.FlagSet, flags *SchemaFlags) {
+	fs.StringVar(&flags.Keyspaces, "keyspace", "", "Keyspace name, or a comma-separated list of keyspaces")
+	fs.StringVar(&flags.KeyspaceRegex, "keyspaceRegex", "", "Also select every non-system keyspace whose name matches this regular expression")
+	fs.StringVar(&flags.Table, "table", "", "Read only this table instead of every table in the keyspace")
+	fs.BoolVar(&flags.Verbose, "verbose", false, "Log connection and progress details")
+	fs.StringVar(&flags.IgnoreFile, "ignoreFile", "", "Path to an ignore file of table and table.column patterns (default ./"+defaultIgnoreFile+" when present)")
+	fs.StringVar(&flags.ExcludeTables, "excludeTables", "", "Comma-separated table name patterns to skip")
+	fs.StringVar(&flags.ExcludeColumns, "excludeColumns", "", "Comma-separated table.column patterns to skip (a bare column name matches every table)")
 }
 
-func generateGoStruct(tableName string, columns map[string]string) (string, error) {
-	structDefinition := fmt.Sprintf("type %s struct {\n", toPascal(tableName))
+// exclusions collects the exclusion flags and the ignore file into one set.
+func (flags SchemaFlags) exclusions() (exclusions, error) {
+	var excluded exclusions
+	for _, pattern := range splitList(flags.ExcludeTables) {
+		if err := excluded.add(pattern); err != nil {
+			return excluded, err
+		}
+	}
+	for _, pattern := range splitList(flags.ExcludeColumns) {
+		if !strings.Contains(pattern, ".") {
+			pattern = "*." + pattern
+		}
+		if err := excluded.add(pattern); err != nil {
+			return excluded, err
+		}
+	}
 
-	for column, cqlType := range columns {
-		goType, err := cqlToGoType(cqlType)
+	ignorePath, required := defaultIgnoreFile, false
+	if flags.IgnoreFile != "" {
+		ignorePath, required = flags.IgnoreFile, true
+	}
+	if err := excluded.loadIgnoreFile(ignorePath, required); err != nil {
+		return excluded, fmt.Errorf("reading ignore file: %w", err)
+	}
 
+	return excluded, nil
+}
+
+// introspect connects to the cluster and fetches the schema of every selected
+// keyspace. On success the caller must close the returned session.
+func introspect(conn ConnectionOptions, flags SchemaFlags, metrics *runMetrics, guard *runGuard) (*gocql.Session, []keyspaceSchema, error) {
+	if flags.Keyspaces == "" && flags.KeyspaceRegex == "" {
+		return nil, nil, fmt.Errorf("keyspace name is required (use -keyspace or -keyspaceRegex)")
+	}
+
+	var keyspacePattern *regexp.Regexp
+	if flags.KeyspaceRegex != "" {
+		pattern, err := regexp.Compile(flags.KeyspaceRegex)
 		if err != nil {
-			return "", err
+			return nil, nil, fmt.Errorf("invalid -keyspaceRegex: %w", err)
 		}
+		keyspacePattern = pattern
+	}
 
-		structDefinition += fmt.Sprintf("    %s %s `json:\"%s\"`\n", strcase.ToCamel(column), goType, column)
+	excluded, err := flags.exclusions()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	structDefinition += "}\n"
-	return structDefinition, nil
-}
+	connectStart := time.Now()
+
+	// Only a single, explicitly named keyspace can be the session keyspace.
+	var sessionKeyspace string
+	if keyspaces := splitList(flags.Keyspaces); len(keyspaces) == 1 && keyspacePattern == nil {
+		sessionKeyspace = keyspaces[0]
+	}
+
+	session, err := connectToScylla(conn, sessionKeyspace)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not connect to ScyllaDB: %w", err)
+	}
+	guard.track(session)
+
+	info, err := pingCluster(session)
+	if err != nil {
+		session.Close()
+		return nil, nil, fmt.Errorf("connected to %s:%d but could not query system.local: %w (check that the user may read system tables)", conn.Host, conn.Port, err)
+	}
 
-func cqlToGoType(cqlType string) (string, error) {
-	cqlType = strings.ToLower(strings.TrimSpace(cqlType))
-	mapPattern := regexp.MustCompile(`^map<(.+),(.+)>$`)
-	listPattern := regexp.MustCompile(`^list<(.+)>$`)
-	setPattern := regexp.MustCompile(`^set<(.+)>$`)
+	metrics.record("connect", connectStart, 0, "")
 
-	if matches := mapPattern.FindStringSubmatch(cqlType); matches != nil {
-		keyType, valueType := matches[1], matches[2]
+	if flags.Verbose {
+		log.Printf("Connected to cluster %q, datacenter %s (release %s, native protocol %s)", info.ClusterName, info.DataCenter, info.ReleaseVersion, info.ProtocolVersion)
+	}
+
+	fetchStart := time.Now()
+	keyspaces := splitList(flags.Keyspaces)
 
-		goKeyType, err := cqlToGoType(keyType)
+	if keyspacePattern != nil {
+		names, err := fetchKeyspaceNames(session)
 		if err != nil {
-			return "", err
+			session.Close()
+			return nil, nil, fmt.Errorf("listing keyspaces: %w", err)
 		}
-		goValueType, err := cqlToGoType(valueType)
-		if err != nil {
-			return "", err
+
+		matched := 0
+		for _, name := range names {
+			if keyspacePattern.MatchString(name) {
+				matched++
+				if !slices.Contains(keyspaces, name) {
+					keyspaces = append(keyspaces, name)
+				}
+			}
 		}
 
-		return fmt.Sprintf("map[%s]%s", goKeyType, goValueType), nil
+		if matched == 0 {
+			log.Printf("Warning: -keyspaceRegex %q matched no keyspaces", flags.KeyspaceRegex)
+		}
 	}
 
-	if matches := listPattern.FindStringSubmatch(cqlType); matches != nil {
-		elemType := matches[1]
+	var schemas []keyspaceSchema
+	for _, keyspace := range keyspaces {
+		if guard.timedOut() {
+			break
+		}
 
-		goElemType, err := cqlToGoType(elemType)
+		schema, err := fetchKeyspaceSchema(session, keyspace, flags.Table, excluded)
+		if err != nil && guard.timedOut() {
+			// The guard closed the session; keep whatever was fetched.
+			if len(schema.Tables) > 0 {
+				schemas = append(schemas, schema)
+			}
+			break
+		}
 		if err != nil {
-			return "", err
+			session.Close()
+			return nil, nil, fmt.Errorf("fetching table definitions for keyspace %s: %w", keyspace, err)
 		}
 
-		return fmt.Sprintf("[]%s", goElemType), nil
+		schemas = append(schemas, schema)
 	}
 
-	if matches := setPattern.FindStringSubmatch(cqlType); matches != nil {
-		elemType := matches[1]
+	tables := 0
+	for _, schema := range schemas {
+		tables += len(schema.Tables)
+	}
+	metrics.record("schema fetch", fetchStart, tables, "tables")
+
+	return session, schemas, nil
+}
+
+// readSchemaJSON reads a schema-json document in place of introspecting a
+// cluster. The keyspace, table and exclusion flags select from it as they
+// would from the cluster; with no keyspace flags every keyspace is used.
+func readSchemaJSON(r io.Reader, flags SchemaFlags) ([]keyspaceSchema, error) {
+	var document schemaDocument
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, fmt.Errorf("decoding schema: %w", err)
+	}
 
-		goElemType, err := cqlToGoType(elemType)
+	var keyspacePattern *regexp.Regexp
+	if flags.KeyspaceRegex != "" {
+		pattern, err := regexp.Compile(flags.KeyspaceRegex)
 		if err != nil {
-			return "", err
-		}
-
-		return fmt.Sprintf("map[%s]struct{}", goElemType), nil
-	}
-
-	switch cqlType {
-	case "uuid", "time.uuid":
-		return "gocql.UUID", nil
-	case "boolean":
-		return "bool", nil
-	case "text", "varchar":
-		return "string", nil
-	case "int":
-		return "int", nil
-	case "bigint":
-		return "int64", nil
-	case "tinyint":
-		return "int8", nil
-	case "smallint":
-		return "int16", nil
-	case "float":
-		return "float32", nil
-	case "double":
-		return "float64", nil
-	case "decimal":
-		return "gocql.Decimal", nil
-	case "timestamp":
-		return "time.Time", nil
-	case "date":
-		return "gocql.Date", nil
-	case "time":
-		return "gocql.Time", nil
-	case "blob":
-		return "[]byte", nil
-	default:
-		return "", fmt.Errorf("unknown CQL type: %s", cqlType)
+			return nil, fmt.Errorf("invalid -keyspaceRegex: %w", err)
+		}
+		keyspacePattern = pattern
 	}
-}
 
-func toPascal(value string) string {
-	camel := strcase.ToCamel(value)
-	return string(unicode.ToUpper(rune(camel[0]))) + camel[1:]
+	excluded, err := flags.exclusions()
+	if err != nil {
+		return nil, err
+	}
+
+	keyspaces := splitList(flags.Keyspaces)
+	for _, name := range keyspaces {
+		if !slices.ContainsFunc(document.Keyspaces, func(schema keyspaceSchema) bool { return schema.Name == name }) {
+			return nil, fmt.Errorf("keyspace %s is not in the schema", name)
+		}
+	}
+
+	var schemas []keyspaceSchema
+	for _, schema := range document.Keyspaces {
+		selected := len(keyspaces) == 0 && keyspacePattern == nil
+		selected = selected || slices.Contains(keyspaces, schema.Name)
+		selected = selected || (keyspacePattern != nil && keyspacePattern.MatchString(schema.Name))
+		if !selected {
+			continue
+		}
+
+		var tables []tableSchema
+		for _, table := range schema.Tables {
+			if flags.Table != "" {
+				if table.Name == flags.Table {
+					tables = append(tables, table)
+				}
+				continue
+			}
+			if excluded.excludesTable(table.Name) {
+				continue
+			}
+
+			var columns []column
+			for _, col := range table.Columns {
+				if !excluded.excludesColumn(table.Name, col.Name) {
+					columns = append(columns, col)
+				}
+			}
+			table.Columns = columns
+			tables = append(tables, table)
+		}
+
+		if flags.Table != "" && len(tables) == 0 {
+			return nil, fmt.Errorf("table %s.%s is not in the schema", schema.Name, flags.Table)
+		}
+
+		schema.Tables = tables
+		schemas = append(schemas, schema)
+	}
+
+	return schemas, nil
 }
 
-func main() {
-	var host string
-	var port int
-	var keyspace string
-	var outputDirectory string
+// skipBadColumns removes every regular or static column whose type has no Go
+// mapping, warning about each, and returns them as keyspace.table.column
+// (type) entries. Key columns are kept, since a struct without them cannot
+// address its rows; they still fail generation.
+func skipBadColumns(schemas []keyspaceSchema, intType string) []string {
+	var skipped []string
+
+	for i := range schemas {
+		schema := &schemas[i]
+		mapper := typeMapper{IntType: intType}.withUserTypes(schema.Types, "")
+
+		for j := range schema.Tables {
+			table := &schema.Tables[j]
+
+			var kept []column
+			for _, col := range table.Columns {
+				if _, err := mapper.cqlToGoType(col.Type); err != nil && !isPrimaryKey(col) {
+					log.Printf("Warning: skipping column %s.%s.%s: %v", schema.Name, table.Name, col.Name, err)
+					skipped = append(skipped, fmt.Sprintf("%s.%s.%s (%s)", schema.Name, table.Name, col.Name, col.Type))
+					continue
+				}
+				kept = append(kept, col)
+			}
+			table.Columns = kept
+		}
+	}
 
-	flag.StringVar(&host, "host", "localhost", "ScyllaDB host address")
-	flag.IntVar(&port, "port", 9042, "ScyllaDB port")
-	flag.StringVar(&keyspace, "keyspace", "", "Keyspace name")
-	flag.StringVar(&outputDirectory, "outputDir", "./outputs", "Relative path to output directory")
+	return skipped
+}
 
-	flag.Parse()
+// applyColumnOrders pins the column order of each table named in orders. A
+// named table must exist in at least one keyspace.
+func applyColumnOrders(schemas []keyspaceSchema, orders map[string][]string) error {
+	for tableName, order := range orders {
+		found := false
+
+		for i := range schemas {
+			for j := range schemas[i].Tables {
+				table := &schemas[i].Tables[j]
+				if table.Name != tableName {
+					continue
+				}
+
+				columns, err := pinColumnOrder(table.Columns, order)
+				if err != nil {
+					return fmt.Errorf("-columnOrder %s: %w", tableName, err)
+				}
+				table.Columns = columns
+				found = true
+			}
+		}
 
-	if keyspace == "" {
-		log.Fatal("Keyspace name is required")
+		if !found {
+			return fmt.Errorf("-columnOrder %s: table not found", tableName)
+		}
 	}
+	return nil
+}
 
-	session, err := connectToScylla(host, port)
-	if err != nil {
-		log.Fatalf("Could not connect to ScyllaDB: %v", err)
+// fetchEnums records the distinct values of each requested enum column that
+// exists in the keyspace. Columns that are not text, cannot be queried or
+// exceed the limit are skipped with a warning.
+func fetchEnums(session *gocql.Session, schema *keyspaceSchema, specs []string, limit int) {
+	for _, spec := range specs {
+		tableName, columnName, _ := strings.Cut(spec, ".")
+
+		for i := range schema.Tables {
+			table := &schema.Tables[i]
+			if table.Name != tableName {
+				continue
+			}
+
+			col, ok := findColumn(table.Columns, columnName)
+			if !ok {
+				log.Printf("Skipping -enums %s: column not found in keyspace %s", spec, schema.Name)
+				continue
+			}
+
+			switch strings.ToLower(col.Type) {
+			case "text", "varchar", "ascii":
+			default:
+				log.Printf("Skipping -enums %s: only text columns are supported, got %s", spec, col.Type)
+				continue
+			}
+
+			values, err := fetchEnumValues(session, schema.Name, tableName, columnName, limit)
+			if err != nil {
+				log.Printf("Skipping -enums %s: %v", spec, err)
+				continue
+			}
+
+			if len(values) > limit {
+				log.Printf("Skipping -enums %s: more than %d distinct values", spec, limit)
+				continue
+			}
+
+			if table.EnumValues == nil {
+				table.EnumValues = make(map[string][]string)
+			}
+			table.EnumValues[columnName] = values
+		}
+	}
+}
+
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+
+	var conn ConnectionOptions
+	var selection SchemaFlags
+	addConnectionFlags(fs, &conn)
+	addSchemaFlags(fs, &selection)
+
+	fs.Parse(args)
+
+	if err := applyConnectionDefaults(fs, &conn); err != nil {
+		log.Fatal(err)
 	}
-	defer session.Close()
 
-	tableNames, err := fetchTableNames(session, keyspace)
+	metrics := &runMetrics{Verbose: selection.Verbose}
+	guard := startRunGuard(conn.Timeout, false)
+	session, schemas, err := introspect(conn, selection, metrics, guard)
 	if err != nil {
-		log.Fatalf("Error fetching table definitions: %v", err)
+		log.Fatal(err)
 	}
+	session.Close()
 
-	var structDefinitions []string
-	for _, tableName := range tableNames {
-		columns, err := fetchColumnDefinitions(session, keyspace, tableName)
-		if err != nil {
-			log.Printf("Error fetching column definitions for table %s: %v", tableName, err)
-			continue
-		}
+	unsupported := 0
 
-		structDef, err := generateGoStruct(tableName, columns)
+	for _, schema := range schemas {
+		mapper := typeMapper{IntType: "exact"}.withUserTypes(schema.Types, "")
 
-		if err != nil {
-			panic(err)
+		fmt.Printf("Keyspace %s: %d tables\n", schema.Name, len(schema.Tables))
+
+		for _, table := range schema.Tables {
+			fmt.Printf("  %s (%d columns)\n", table.Name, len(table.Columns))
+
+			for _, col := range table.Columns {
+				goType, err := mapper.cqlToGoType(col.Type)
+				if err != nil {
+					goType = "unsupported: " + err.Error()
+					unsupported++
+				}
+				fmt.Printf("    %-24s %-24s %-14s %s\n", col.Name, col.Type, col.Kind, goType)
+			}
 		}
+	}
 
-		structDefinitions = append(structDefinitions, structDef)
+	if unsupported > 0 {
+		fmt.Printf("%d columns have unsupported types\n", unsupported)
+		os.Exit(1)
 	}
+}
 
-	dirPath := outputDirectory + "/" + keyspace
-	filePath := dirPath + "/main.go"
+func runGenerate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+
+	var opts Options
+	addConnectionFlags(fs, &opts.Connection)
+	addSchemaFlags(fs, &opts.Selection)
+	opts.ColumnOrders = make(map[string][]string)
+	opts.Defaults = make(map[string]map[string]string)
+
+	fs.StringVar(&opts.OutputDir, "outputDir", "./models", "Relative path to output directory; each keyspace gets a subdirectory")
+	fs.StringVar(&opts.OutputFile, "outputFile", "", "Write the output to this exact path instead of under -outputDir (the output must be a single file)")
+	fs.StringVar(&opts.Package, "package", "models", "Package name for generated Go code")
+	fs.StringVar(&opts.InitModule, "initModule", "", "Also write a go.mod with this module path and the gocql dependency to the output directory, unless one exists")
+	fs.StringVar(&opts.Formats, "format", "go", "Comma-separated output formats: "+strings.Join(formatNames, ", ")+" (schema-json or describe alone prints to stdout; several formats go into per-format subdirectories)")
+	fs.BoolVar(&opts.Gocqlx, "gocqlx", false, "Generate gocqlx table metadata and select builders")
+	fs.BoolVar(&opts.FieldMap, "fieldMap", false, "Generate a Go field name to CQL column name map per table")
+	fs.StringVar(&opts.Embed, "embed", "", "Comma-separated columns to factor into a shared embedded struct when a table has all of them")
+	fs.StringVar(&opts.EmbedName, "embedName", "Audit", "Name of the shared struct generated for -embed")
+	fs.StringVar(&opts.GocqlImport, "gocqlImport", defaultGocqlImport, "Import path of the gocql package referenced by generated types")
+	fs.StringVar(&opts.BuildTags, "buildTags", "", "Build constraint expression written as a //go:build line in generated files")
+	fs.StringVar(&opts.IntType, "intType", "", "Integer mapping: exact (width-matched, but int becomes Go int), strict (int becomes int32) or int64 (every integer type becomes int64); default exact")
+	fs.BoolVar(&opts.StrictInt, "strictInt", false, "Map CQL int to int32 and bigint and counter to int64 for fixed-width fields (same as -intType strict)")
+	fs.BoolVar(&opts.OrderedMaps, "orderedMaps", false, "Map CQL maps to a generated OrderedMap type that keeps the server's entry order instead of native Go maps")
+	fs.StringVar(&opts.JSONCase, "jsonCase", "column", "json tag naming: "+strings.Join(jsonCases, ", ")+" (db and cql tags always keep the column name)")
+	fs.BoolVar(&opts.Fingerprint, "fingerprint", false, "Generate a SchemaFingerprint constant per keyspace, a hash of its tables, columns and types for detecting schema drift")
+	fs.StringVar(&opts.ExtraTags, "extraTags", "", "Comma-separated key=style list of extra struct tags named from the column, e.g. bson=snake,yaml=original (styles: original, "+strings.Join(jsonCases, ", ")+")")
+	fs.BoolVar(&opts.NoJSON, "noJSON", false, "Leave json tags out of generated structs; fields without any other tag get none")
+	fs.BoolVar(&opts.Registry, "registry", false, "Generate an AllTables variable listing every generated table")
+	fs.BoolVar(&opts.Diff, "diff", false, "Print a diff against the existing generated files instead of writing, exiting non-zero on differences")
+	fs.BoolVar(&opts.ValidateTags, "validateTags", false, "Add validate:\"required\" tags to partition and clustering key fields")
+	fs.BoolVar(&opts.TypeComments, "typeComments", false, "Annotate each field with its CQL type and static-ness")
+	fs.BoolVar(&opts.SplitFiles, "splitFiles", false, "Write one file per table plus "+sharedFileName+" for shared declarations and a "+docFileName+" package overview")
+	fs.BoolVar(&opts.Incremental, "incremental", false, "With -splitFiles, rewrite only the files of tables whose schema changed since the last run and remove those of dropped tables, tracked in "+manifestFileName)
+	fs.StringVar(&opts.FileMode, "fileMode", "0644", "Octal permissions of written files")
+	fs.StringVar(&opts.DirMode, "dirMode", "0755", "Octal permissions of created output directories")
+	fs.BoolVar(&opts.Clean, "clean", false, "Remove previously generated files that are no longer produced (hand-written files are never touched)")
+	fs.BoolVar(&opts.Force, "force", false, "Replace existing output files even if they are read-only or were not generated by go-cql-scaffold, and regenerate even if the schema is unchanged")
+	fs.StringVar(&opts.Indent, "indent", "tab", "Indentation of raw generated code: tab or a number of spaces (the format pass normalizes to tabs)")
+	fs.StringVar(&opts.LineEnding, "lineEnding", "lf", "Line endings of generated files: lf or crlf, applied after formatting")
+	fs.BoolVar(&opts.NoFormat, "noFormat", false, "Skip the go/format pass and write the raw generated code")
+	fs.StringVar(&opts.Enums, "enums", "", "Comma-separated table.column list of text columns to generate constants for from their distinct values (queries table data; CQL only allows DISTINCT on partition key columns)")
+	fs.IntVar(&opts.EnumLimit, "enumLimit", 50, "Maximum number of distinct values accepted for an -enums column")
+	fs.BoolVar(&opts.PartitionKeyStruct, "partitionKeyStruct", false, "Generate a <Table>Partition struct with Partition and SetPartition methods for tables with composite partition keys")
+	fs.BoolVar(&opts.RoutingKey, "routingKey", false, "Generate partition key column lists and RoutingKey methods for token-aware routing")
+	fs.Func("columnOrder", "Pin the field order of a table as table=col1,col2,...; unlisted columns follow in the default order (repeatable)", func(value string) error {
+		tableName, columnList, ok := strings.Cut(value, "=")
+		if !ok || tableName == "" || len(splitList(columnList)) == 0 {
+			return fmt.Errorf("expected table=col1,col2,...")
+		}
+		opts.ColumnOrders[tableName] = splitList(columnList)
+		return nil
+	})
+	fs.StringVar(&opts.MetricsJSON, "metricsJSON", "", "Write a JSON report of how long each phase of the run took to this file")
+	fs.StringVar(&opts.HeaderFile, "headerFile", "", "File whose contents, e.g. a license or SPDX header, are added as comments to the top of each Go and TypeScript file")
+	fs.BoolVar(&opts.Constructors, "constructors", false, "Generate a New<Table> function per table that returns a struct with empty, non-nil map and set fields")
+	fs.Func("defaults", "Seed a scalar column in the New<Table> functions as table.column=value, checked against the column type (repeatable; implies -constructors)", func(value string) error {
+		target, defaultValue, ok := strings.Cut(value, "=")
+		tableName, columnName, dotted := strings.Cut(target, ".")
+		if !ok || !dotted || tableName == "" || columnName == "" {
+			return fmt.Errorf("expected table.column=value")
+		}
+		if opts.Defaults[tableName] == nil {
+			opts.Defaults[tableName] = make(map[string]string)
+		}
+		opts.Defaults[tableName][columnName] = defaultValue
+		return nil
+	})
+	fs.BoolVar(&opts.UDTMarshalers, "udtMarshalers", false, "Generate MarshalUDT and UnmarshalUDT methods for user-defined type structs instead of relying on cql tag reflection")
+	fs.BoolVar(&opts.JSONEmptyCollections, "jsonEmptyCollections", false, "Generate MarshalJSON methods that write nil list, set and map fields as [] and {} instead of null")
+	fs.BoolVar(&opts.Accessors, "accessors", false, "Generate Get<Field> methods for list, set and map fields that never return nil")
+	fs.BoolVar(&opts.DocComments, "docComments", false, "Add a doc comment to each table struct from the table's CQL comment")
+	fs.BoolVar(&opts.PrefixKeyspace, "prefixKeyspace", false, "Prefix generated type and variable names with the keyspace name, e.g. AppUser, and write every keyspace into one package directory")
+	fs.BoolVar(&opts.Insert, "insert", false, "Generate a <Table>Insert statement and a BindArgs method returning the fields in its column order")
+	fs.BoolVar(&opts.Methods, "methods", false, "Generate a <Table>Repo per table with context-aware GetByKey, DeleteByKey and Insert methods (implies -insert)")
+	fs.BoolVar(&opts.IndexMethods, "indexMethods", false, "Generate a <Table>Repo GetBy<Field> method per secondary index, read from system_schema.indexes (implies -methods)")
+	fs.BoolVar(&opts.BatchHelpers, "batchHelpers", false, "Generate a <Table>InsertBatch function inserting a slice of rows in one batch (implies -insert)")
+	fs.StringVar(&opts.BatchType, "batchType", "unlogged", "Batch type of -batchHelpers: logged or unlogged")
+	fs.BoolVar(&opts.IterWrapper, "iterWrapper", false, "Generate a typed <Table>Iter wrapper around gocql.Iter for each table")
+	fs.BoolVar(&opts.PartialOnTimeout, "partialOnTimeout", false, "When -timeout expires, generate from the schema fetched so far, mark the files as partial and exit non-zero")
+	fs.BoolVar(&opts.SelfTest, "selfTest", false, "Before generating, scan one real row of the -table table into each generated field type and fail on mismatches (reads table data)")
+	schemaStdin := fs.Bool("schemaStdin", false, "Read the schema as a schema-json document from stdin instead of connecting to a cluster")
+	fs.IntVar(&opts.MaxTables, "maxTables", 500, "Abort when a keyspace has more tables than this, as a guard against selecting the wrong keyspace (0 disables the limit)")
+	fs.BoolVar(&opts.SkipBadColumns, "skipBadColumns", false, "Skip non-key columns whose CQL type has no Go mapping, with a warning, instead of failing their table")
+	fs.StringVar(&opts.NullType, "nullType", "", "Typing of nullable (non-primary-key) scalar columns: none, pointers or sql (database/sql null types, which gocql cannot scan into); default none")
+	fs.StringVar(&opts.FieldNamesFile, "fieldNames", "", "File of table.column FieldName lines naming struct fields where the derived names do not fit")
+	fs.StringVar(&opts.PointerColumns, "pointerColumns", "", "Comma-separated table.column list of nullable columns to make pointers regardless of -nullType; prefix an entry with ! to keep its plain type")
+	fs.BoolVar(&opts.Pointers, "pointers", false, "Generate pointer fields for nullable (non-primary-key) scalar columns (same as -nullType pointers)")
+	fs.BoolVar(&opts.OmitEmpty, "omitempty", false, "Add omitempty to json tags of nullable (non-primary-key) columns")
+
+	fs.Parse(args)
+
+	if err := applyConnectionDefaults(fs, &opts.Connection); err != nil {
+		log.Fatal(err)
+	}
+	if *schemaStdin {
+		opts.Schema = os.Stdin
+	}
 
-	err = os.MkdirAll(dirPath, os.ModePerm)
+	// A zero Options.MaxTables selects the default, so the flag's 0 is
+	// passed on as the negative value that disables the limit.
+	if opts.MaxTables == 0 {
+		opts.MaxTables = -1
+	}
+	// Likewise -enumLimit 0 must not silently become the default; as -1 it
+	// fails validation instead.
+	if opts.EnumLimit == 0 {
+		opts.EnumLimit = -1
+	}
 
+	opts = opts.withDefaults()
+	if err := opts.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	genOpts, err := opts.generateOptions()
 	if err != nil {
-		fmt.Println("Error creating directory:", err)
-		return
+		log.Fatal(err)
 	}
 
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_RDWR, 0644)
+	formats, _ := parseFormats(opts.Formats)
+	modes, _ := opts.outputModes()
 
+	metrics := &runMetrics{Verbose: opts.Selection.Verbose}
+	guard := startRunGuard(opts.Connection.Timeout, opts.PartialOnTimeout)
+
+	// Generation happens in memory, as for GenerateToBuffer; the rest of the
+	// run decides what of it to print, compare or write.
+	result, err := generateToBuffer(opts, genOpts, metrics, guard)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
+	}
+	schemas, skipped, files, partial := result.Schemas, result.Skipped, result.Files, result.Partial
+
+	// On their own, schema-json and describe are inspection aids printed to
+	// stdout unless -outputFile names a file for them.
+	if len(formats) == 1 && opts.OutputFile == "" {
+		switch formats[0] {
+		case "schema-json":
+			content, err := encodeSchemaJSON(schemas, "")
+			if err != nil {
+				log.Fatalf("Error encoding schema: %v", err)
+			}
+			os.Stdout.Write(content)
+			return
+		case "describe":
+			os.Stdout.WriteString(describeSchemas(schemas))
+			return
+		}
 	}
 
-	defer file.Close()
+	// Unchanged schemas and options produce unchanged output, so a run whose
+	// hashes match the last write, and whose output is still as written, has
+	// nothing to write. -incremental tracks tables in its own manifest, which
+	// also notices a table file deleted since, so it skips the cache.
+	fingerprint, err := opts.outputFingerprint(genOpts)
+	if err != nil {
+		log.Fatalf("Error hashing options: %v", err)
+	}
+	hashes, err := hashSchemas(schemas, fingerprint)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !opts.Diff && !opts.Force && !opts.Incremental && opts.PostProcess == nil {
+		cached, err := loadHashCache(opts.hashCachePath())
+		if err != nil {
+			log.Printf("Ignoring schema hash cache: %v", err)
+		} else if cached.matches(hashes) && cached.outputIntact(filepath.Dir(opts.hashCachePath())) {
+			log.Printf("Schema unchanged since the last run; leaving the output as it is (use -force to rewrite it)")
+			return
+		}
+	}
 
-	file.WriteString("package main\n")
+	if opts.OutputFile == "" {
+		for i := range files {
+			files[i].Path = opts.OutputDir + "/" + files[i].Path
+		}
+	}
 
-	for _, structDefinition := range structDefinitions {
-		_, err = file.WriteString(structDefinition)
+	if len(skipped) > 0 {
+		log.Printf("Skipped %d columns with unsupported types: %s", len(skipped), strings.Join(skipped, ", "))
+	}
 
-		if err != nil {
-			fmt.Println("Error writing to file:", err)
+	writeMetrics := func() {
+		if opts.MetricsJSON == "" {
 			return
 		}
+		if err := metrics.writeJSON(opts.MetricsJSON, modes.File); err != nil {
+			log.Printf("Error writing -metricsJSON: %v", err)
+		}
+	}
+
+	if opts.Diff {
+		diffStart := time.Now()
+		changed, err := diffGeneratedFiles(files)
+		if err != nil {
+			log.Fatalf("Error comparing generated files: %v", err)
+		}
+		metrics.record("diff", diffStart, len(files), "files")
+		writeMetrics()
+
+		if changed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// With -incremental only the files of added and changed tables are
+	// rewritten; a partial run has not seen every table, so it removes none.
+	writeFiles := files
+	var manifest generationManifest
+	var removedFiles []string
+	if opts.Incremental {
+		manifestDir := filepath.Dir(opts.manifestPath())
+		manifest, err = buildManifest(schemas, files, fingerprint, manifestDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		manifest.Selection, err = opts.Selection.tableSelection()
+		if err != nil {
+			log.Fatal(err)
+		}
+		previous, err := loadManifest(opts.manifestPath())
+		if err != nil {
+			log.Printf("Ignoring manifest: %v", err)
+		}
+
+		var changes tableChanges
+		var changed []generatedFile
+		changed, removedFiles, changes = planIncremental(files, previous, manifest, manifestDir)
+		if !opts.Force {
+			writeFiles = changed
+		}
+		if partial {
+			removedFiles = nil
+		}
+		changes.report()
 	}
 
-	fmt.Println("String written to file successfully.")
+	writeStart := time.Now()
+	if err := writeGeneratedFiles(writeFiles, opts.Clean, opts.Force, modes, guard); err != nil {
+		log.Fatal(err)
+	}
+	if err := removeTableFiles(removedFiles); err != nil {
+		log.Fatal(err)
+	}
+	metrics.record("write", writeStart, len(writeFiles), "files")
+
+	if opts.InitModule != "" {
+		if err := opts.writeModuleFile(modes); err != nil {
+			log.Fatalf("Error writing go.mod: %v", err)
+		}
+	}
+
+	if partial {
+		// The cache must not make the next run skip a complete generation.
+		writeMetrics()
+		log.Printf("Warning: the output is partial because the run timed out; tables fetched after the timeout are missing")
+		os.Exit(1)
+	}
+
+	if err := hashes.recordFiles(files, filepath.Dir(opts.hashCachePath())); err != nil {
+		log.Printf("Error writing schema hash cache: %v", err)
+	} else if err := writeHashCache(opts.hashCachePath(), hashes, modes); err != nil {
+		log.Printf("Error writing schema hash cache: %v", err)
+	}
+	if opts.Incremental {
+		if err := writeManifest(opts.manifestPath(), manifest, modes); err != nil {
+			log.Printf("Error writing manifest: %v", err)
+		}
+	}
+	writeMetrics()
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withStdin runs fn with os.Stdin reading the file at path, as -schemaStdin
+// runs read their schema.
+func withStdin(t *testing.T, path string, fn func()) {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	stdin := os.Stdin
+	os.Stdin = file
+	defer func() { os.Stdin = stdin }()
+
+	fn()
+}
+
+func TestIncrementalRegeneratesDeletedTableFile(t *testing.T) {
+	dir := t.TempDir()
+	args := []string{"-schemaStdin", "-keyspace", "shop", "-splitFiles", "-incremental", "-outputDir", dir}
+	schema := filepath.Join("testdata", "schema.json")
+
+	withStdin(t, schema, func() { runGenerate(args) })
+
+	tableFile := filepath.Join(dir, "shop", "orders.go")
+	if _, err := os.Stat(tableFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(tableFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// The schema is unchanged, but the file must come back.
+	withStdin(t, schema, func() { runGenerate(args) })
+	if _, err := os.Stat(tableFile); err != nil {
+		t.Errorf("the deleted table file was not regenerated: %v", err)
+	}
+}
+
+func TestIncrementalKeepsFilesOfUnselectedTables(t *testing.T) {
+	dir := t.TempDir()
+	args := []string{"-schemaStdin", "-keyspace", "shop", "-splitFiles", "-incremental", "-outputDir", dir}
+	schema := filepath.Join("testdata", "schema.json")
+
+	withStdin(t, schema, func() { runGenerate(args) })
+
+	// page_views is filtered out, not dropped, so its file must stay.
+	tableFile := filepath.Join(dir, "shop", "page_views.go")
+	for _, narrower := range [][]string{{"-table", "orders"}, {"-excludeTables", "page_views"}} {
+		withStdin(t, schema, func() { runGenerate(append(narrower, args...)) })
+		if _, err := os.Stat(tableFile); err != nil {
+			t.Errorf("a run with %v removed the file of an unselected table: %v", narrower, err)
+		}
+	}
+
+	manifest, err := loadManifest(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := manifest.Tables["shop.page_views"]; !ok {
+		t.Errorf("the manifest no longer tracks shop.page_views: %v", manifest.Tables)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// manifestFileName is the -incremental manifest. It is not .cqlscaffold.json,
+// which already names the -config file.
+const manifestFileName = ".cqlscaffold.manifest.json"
+
+// generationManifest records, per keyspace.table, the schema hash and file of
+// every table file the last -incremental run wrote, and how that run selected
+// its tables. File paths are relative to the manifest's directory.
+type generationManifest struct {
+	Selection tableSelection           `json:"selection"`
+	Tables    map[string]manifestEntry `json:"tables"`
+}
+
+// tableSelection holds the flags that decide which tables a run reads. Only
+// runs with the same selection can tell a dropped table from a filtered one.
+type tableSelection struct {
+	Keyspaces     []string `json:"keyspaces,omitempty"`
+	KeyspaceRegex string   `json:"keyspaceRegex,omitempty"`
+	Table         string   `json:"table,omitempty"`
+	ExcludeTables []string `json:"excludeTables,omitempty"`
+}
+
+type manifestEntry struct {
+	Hash string `json:"hash"`
+	File string `json:"file"`
+}
+
+// tableChanges lists the keyspace.table names of an -incremental run by how
+// they differ from the manifest.
+type tableChanges struct {
+	Added      []string
+	Changed    []string
+	Removed    []string
+	Unselected []string
+	Unchanged  []string
+}
+
+// manifestPath returns where the manifest lives, next to the hash cache.
+func (o Options) manifestPath() string {
+	return filepath.Join(filepath.Dir(o.hashCachePath()), manifestFileName)
+}
+
+// tableSelection returns the selection the flags make, with the table
+// patterns of the ignore file included.
+func (flags SchemaFlags) tableSelection() (tableSelection, error) {
+	excluded, err := flags.exclusions()
+	if err != nil {
+		return tableSelection{}, err
+	}
+
+	selection := tableSelection{
+		Keyspaces:     splitList(flags.Keyspaces),
+		KeyspaceRegex: flags.KeyspaceRegex,
+		Table:         flags.Table,
+		ExcludeTables: excluded.Tables,
+	}
+	sort.Strings(selection.Keyspaces)
+	sort.Strings(selection.ExcludeTables)
+	return selection, nil
+}
+
+// buildManifest hashes the table of every per-table file in files. A table's
+// hash covers the options fingerprint and its keyspace's user-defined types
+// as well as its own schema, since both shape its file.
+func buildManifest(schemas []keyspaceSchema, files []generatedFile, fingerprint []byte, manifestDir string) (generationManifest, error) {
+	manifest := generationManifest{Tables: make(map[string]manifestEntry)}
+
+	hashes := make(map[string]string)
+	for _, schema := range schemas {
+		types, err := json.Marshal(schema.Types)
+		if err != nil {
+			return manifest, fmt.Errorf("hashing keyspace %s: %w", schema.Name, err)
+		}
+
+		for _, table := range schema.Tables {
+			content, err := json.Marshal(table)
+			if err != nil {
+				return manifest, fmt.Errorf("hashing table %s.%s: %w", schema.Name, table.Name, err)
+			}
+
+			sum := sha256.New()
+			sum.Write(fingerprint)
+			sum.Write(types)
+			sum.Write(content)
+			hashes[schema.Name+"."+table.Name] = hex.EncodeToString(sum.Sum(nil))
+		}
+	}
+
+	for _, file := range files {
+		if file.Table == "" {
+			continue
+		}
+		relative, err := filepath.Rel(manifestDir, file.Path)
+		if err != nil {
+			return manifest, err
+		}
+		manifest.Tables[file.Table] = manifestEntry{Hash: hashes[file.Table], File: filepath.ToSlash(relative)}
+	}
+
+	return manifest, nil
+}
+
+// loadManifest reads the manifest at path. A missing file is an empty
+// manifest, so every table counts as added.
+func loadManifest(path string) (generationManifest, error) {
+	manifest := generationManifest{Tables: make(map[string]manifestEntry)}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return manifest, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if manifest.Tables == nil {
+		manifest.Tables = make(map[string]manifestEntry)
+	}
+	return manifest, nil
+}
+
+// planIncremental compares current with the previous manifest. It returns
+// the files to write, which are every file that is not a table file plus the
+// files of added and changed tables, and the paths of files whose tables were
+// dropped. A table whose file has gone missing from disk is rewritten too.
+//
+// A table missing from current counts as dropped only when both runs used the
+// same selection. Otherwise it may just be outside this run's selection, so
+// its file is kept and its entry is carried over into current.
+func planIncremental(files []generatedFile, previous generationManifest, current generationManifest, manifestDir string) ([]generatedFile, []string, tableChanges) {
+	var changes tableChanges
+	var write []generatedFile
+
+	for _, file := range files {
+		if file.Table == "" {
+			write = append(write, file)
+			continue
+		}
+
+		entry, ok := previous.Tables[file.Table]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, file.Table)
+		case entry.Hash != current.Tables[file.Table].Hash || entry.File != current.Tables[file.Table].File:
+			changes.Changed = append(changes.Changed, file.Table)
+		default:
+			if _, err := os.Stat(file.Path); err != nil {
+				changes.Changed = append(changes.Changed, file.Table)
+				break
+			}
+			changes.Unchanged = append(changes.Unchanged, file.Table)
+			continue
+		}
+		write = append(write, file)
+	}
+
+	sameSelection := reflect.DeepEqual(previous.Selection, current.Selection)
+	var removed []string
+	for table, entry := range previous.Tables {
+		if _, ok := current.Tables[table]; ok {
+			continue
+		}
+		if !sameSelection {
+			changes.Unselected = append(changes.Unselected, table)
+			current.Tables[table] = entry
+			continue
+		}
+		changes.Removed = append(changes.Removed, table)
+		removed = append(removed, filepath.Join(manifestDir, filepath.FromSlash(entry.File)))
+	}
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Unselected)
+	sort.Strings(removed)
+
+	return write, removed, changes
+}
+
+// removeTableFiles deletes the files of dropped tables. Files that are gone
+// already or no longer carry the generated marker are left alone.
+func removeTableFiles(paths []string) error {
+	for _, filePath := range paths {
+		generated, err := isGeneratedFile(filePath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("checking %s: %w", filePath, err)
+		}
+		if !generated {
+			continue
+		}
+
+		if err := os.Remove(filePath); err != nil {
+			return fmt.Errorf("removing %s: %w", filePath, err)
+		}
+		fmt.Printf("Removed %s\n", filePath)
+	}
+	return nil
+}
+
+// report prints each non-empty group of tables.
+func (c tableChanges) report() {
+	for _, group := range []struct {
+		label  string
+		tables []string
+	}{
+		{"Added", c.Added},
+		{"Changed", c.Changed},
+		{"Removed", c.Removed},
+		{"Kept unselected", c.Unselected},
+	} {
+		if len(group.tables) > 0 {
+			fmt.Printf("%s tables: %s\n", group.label, strings.Join(group.tables, ", "))
+		}
+	}
+	fmt.Printf("Unchanged tables: %d\n", len(c.Unchanged))
+}
+
+func writeManifest(path string, manifest generationManifest, modes outputModes) error {
+	content, err := marshalJSON(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), modes.Dir); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, modes.File)
+}
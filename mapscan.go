@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+)
+
+// emitMapScan writes one Go file per keyspace with a function per table that
+// scans the next row of an iterator into a map[string]interface{}. Values are
+// scanned into the concretely typed Go equivalents of their CQL types first,
+// so callers get the same types the struct output would use.
+func emitMapScan(schemas []keyspaceSchema, opts generateOptions) ([]generatedFile, error) {
+	var files []generatedFile
+
+	// The helpers do not use gocqlx, and a standalone file must not import it.
+	opts.Gocqlx = false
+
+	for _, schema := range schemas {
+		prefix := opts.typePrefix(schema.Name)
+		mapper := opts.Struct.Types.withUserTypes(schema.Types, prefix)
+		udtOpts := opts.Struct
+		udtOpts.Types = mapper
+
+		var declarations []string
+		for _, udt := range schema.Types {
+			udtDef, err := generateUDTStruct(udt, udtOpts)
+			if err != nil {
+				return nil, fmt.Errorf("keyspace %s type %s: %w", schema.Name, udt.Name, err)
+			}
+			declarations = append(declarations, udtDef)
+		}
+
+		for _, table := range schema.Tables {
+			scanFunc, err := generateMapScan(prefix+toPascal(table.Name), table.Columns, mapper)
+			if err != nil {
+				return nil, fmt.Errorf("keyspace %s table %s: %w", schema.Name, table.Name, err)
+			}
+			declarations = append(declarations, scanFunc)
+		}
+		if usesOrderedMap(mapper.OrderedMap, declarations) {
+			declarations = append(declarations, generateOrderedMap(mapper.OrderedMap))
+		}
+
+		content, err := renderGoFile(declarations, opts)
+		if err != nil {
+			return nil, fmt.Errorf("keyspace %s: %w", schema.Name, err)
+		}
+
+		files = append(files, generatedFile{Path: sanitizePathComponent(schema.Name) + "/mapscan.go", Content: content})
+	}
+
+	return files, nil
+}
+
+// generateMapScan emits the column list and Scan<Table>Map function for a
+// table. The iterator must select exactly the listed columns, in order.
+func generateMapScan(name string, columns []column, mapper typeMapper) (string, error) {
+	code := fmt.Sprintf("// %sMapColumns lists the columns Scan%sMap expects, in select order.\n", name, name)
+	code += fmt.Sprintf("var %sMapColumns = %s\n\n", name, goStringSlice(columnNames(columns)))
+
+	code += fmt.Sprintf("// Scan%sMap scans the next row of iter into a map keyed by column name.\n", name)
+	code += "// It returns false when there are no more rows or scanning failed; check\n"
+	code += "// iter.Close for the error.\n"
+	code += fmt.Sprintf("func Scan%sMap(iter *gocql.Iter) (map[string]interface{}, bool) {\n", name)
+
+	fields := uniqueFieldNames(columnNames(columns), nil)
+
+	var variables []string
+	for _, col := range columns {
+		goType, err := mapper.cqlToGoType(col.Type)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", col.Name, err)
+		}
+
+		variable := scanVariableName(fields[col.Name])
+		variables = append(variables, variable)
+		code += fmt.Sprintf("    var %s %s\n", variable, goType)
+	}
+
+	code += fmt.Sprintf("    if !iter.Scan(&%s) {\n", strings.Join(variables, ", &"))
+	code += "        return nil, false\n"
+	code += "    }\n"
+	code += "    return map[string]interface{}{\n"
+	for i, col := range columns {
+		code += fmt.Sprintf("        %q: %s,\n", col.Name, variables[i])
+	}
+	code += "    }, true\n"
+	code += "}\n"
+
+	return code, nil
+}
+
+// scanVariableName turns a struct field name into a local variable name that
+// cannot clash with Go keywords or the iter parameter.
+func scanVariableName(field string) string {
+	name := strcase.ToLowerCamel(field)
+	if name == "" || token.IsKeyword(name) || name == "iter" {
+		name += "Value"
+	}
+	return name
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const marshalPackage = "org.apache.cassandra.db.marshal."
+
+// marshalScalarTypes maps Cassandra's internal marshaller class names to the
+// CQL types they implement.
+var marshalScalarTypes = map[string]string{
+	"AsciiType":         "ascii",
+	"BooleanType":       "boolean",
+	"ByteType":          "tinyint",
+	"BytesType":         "blob",
+	"CounterColumnType": "counter",
+	"DateType":          "timestamp",
+	"DecimalType":       "decimal",
+	"DoubleType":        "double",
+	"DurationType":      "duration",
+	"FloatType":         "float",
+	"InetAddressType":   "inet",
+	"Int32Type":         "int",
+	"IntegerType":       "varint",
+	"LongType":          "bigint",
+	"ShortType":         "smallint",
+	"SimpleDateType":    "date",
+	"TimeType":          "time",
+	"TimeUUIDType":      "timeuuid",
+	"TimestampType":     "timestamp",
+	"UTF8Type":          "text",
+	"UUIDType":          "uuid",
+}
+
+// isMarshalType reports whether a type string is an internal class name, as
+// older servers return, rather than a CQL type.
+func isMarshalType(cqlType string) bool {
+	return strings.Contains(cqlType, marshalPackage)
+}
+
+// normalizeFetchedType returns the CQL form of a type read from the schema
+// tables. Class names that cannot be translated are kept, with a warning
+// naming where they were found, and fail later as unknown types.
+func normalizeFetchedType(location string, cqlType string) string {
+	if !isMarshalType(cqlType) {
+		return cqlType
+	}
+
+	translated, err := marshalToCQLType(cqlType)
+	if err != nil {
+		log.Printf("Warning: %s has the internal type %s, which has no CQL equivalent here: %v", location, cqlType, err)
+		return cqlType
+	}
+	return translated
+}
+
+// marshalToCQLType translates an internal class name such as
+// org.apache.cassandra.db.marshal.MapType(...UTF8Type,...Int32Type) into the
+// CQL type map<text, int>. ReversedType is dropped because clustering order
+// is read separately.
+func marshalToCQLType(className string) (string, error) {
+	className = strings.TrimSpace(strings.ReplaceAll(className, marshalPackage, ""))
+
+	name, args, err := splitMarshalArgs(className)
+	if err != nil {
+		return "", err
+	}
+
+	if len(args) == 0 {
+		if cqlType, ok := marshalScalarTypes[name]; ok {
+			return cqlType, nil
+		}
+		return "", fmt.Errorf("unknown marshal type %s", name)
+	}
+
+	if name == "UserType" {
+		// UserType(keyspace,hex name,hex field:type,...)
+		if len(args) < 2 {
+			return "", fmt.Errorf("malformed UserType(%s)", strings.Join(args, ","))
+		}
+		typeName, err := hex.DecodeString(args[1])
+		if err != nil {
+			return "", fmt.Errorf("malformed UserType name %q: %w", args[1], err)
+		}
+		return string(typeName), nil
+	}
+
+	params := make([]string, len(args))
+	for i, arg := range args {
+		param, err := marshalToCQLType(arg)
+		if err != nil {
+			return "", err
+		}
+		params[i] = param
+	}
+
+	switch {
+	case name == "ReversedType" && len(params) == 1:
+		return params[0], nil
+	case name == "FrozenType" && len(params) == 1:
+		return "frozen<" + params[0] + ">", nil
+	case name == "ListType" && len(params) == 1:
+		return "list<" + params[0] + ">", nil
+	case name == "SetType" && len(params) == 1:
+		return "set<" + params[0] + ">", nil
+	case name == "MapType" && len(params) == 2:
+		return "map<" + params[0] + ", " + params[1] + ">", nil
+	case name == "TupleType":
+		return "tuple<" + strings.Join(params, ", ") + ">", nil
+	}
+
+	return "", fmt.Errorf("unknown marshal type %s(%s)", name, strings.Join(args, ","))
+}
+
+// splitMarshalArgs splits "Name(a,B(c,d))" into Name and its top-level
+// arguments [a, B(c,d)].
+func splitMarshalArgs(className string) (string, []string, error) {
+	open := strings.IndexByte(className, '(')
+	if open < 0 {
+		return className, nil, nil
+	}
+	if !strings.HasSuffix(className, ")") {
+		return "", nil, fmt.Errorf("malformed marshal type %s", className)
+	}
+
+	name := className[:open]
+	inner := className[open+1 : len(className)-1]
+
+	var args []string
+	depth, start := 0, 0
+	for i, r := range inner {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return "", nil, fmt.Errorf("malformed marshal type %s", className)
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(inner[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return "", nil, fmt.Errorf("malformed marshal type %s", className)
+	}
+
+	return name, append(args, strings.TrimSpace(inner[start:])), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// runMetrics records how long each phase of a run took. With Verbose, every
+// phase is also logged as it completes.
+type runMetrics struct {
+	Verbose bool
+	Phases  []phaseMetric
+}
+
+type phaseMetric struct {
+	Name       string  `json:"name"`
+	DurationMS float64 `json:"duration_ms"`
+	Count      int     `json:"count"`
+	Unit       string  `json:"unit,omitempty"`
+}
+
+// record adds a phase that started at start and processed count units.
+func (m *runMetrics) record(name string, start time.Time, count int, unit string) {
+	elapsed := time.Since(start)
+	m.Phases = append(m.Phases, phaseMetric{
+		Name:       name,
+		DurationMS: float64(elapsed.Microseconds()) / 1000,
+		Count:      count,
+		Unit:       unit,
+	})
+
+	if m.Verbose {
+		if unit != "" {
+			log.Printf("%s took %s (%d %s)", name, elapsed.Round(time.Millisecond), count, unit)
+		} else {
+			log.Printf("%s took %s", name, elapsed.Round(time.Millisecond))
+		}
+	}
+}
+
+// writeJSON writes the recorded phases and their total to filePath.
+func (m *runMetrics) writeJSON(filePath string, mode os.FileMode) error {
+	total := 0.0
+	for _, phase := range m.Phases {
+		total += phase.DurationMS
+	}
+
+	content, err := marshalJSON(map[string]interface{}{
+		"phases":   m.Phases,
+		"total_ms": total,
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, content, mode)
+}
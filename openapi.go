@@ -0,0 +1,165 @@
+package main
+
+import "fmt"
+
+const openAPIVersion = "3.0.3"
+
+// openAPIUDTSuffix ends the component names of user-defined types, which
+// share components.schemas with the tables, so a type and a table of the same
+// name both keep their schema.
+const openAPIUDTSuffix = "UDT"
+
+// emitOpenAPI writes an OpenAPI 3.0 document per keyspace whose
+// components.schemas hold an object schema for every user-defined type and
+// table. Primary key properties are required. Names that still collide, such
+// as two tables that differ only in case, are an error.
+func emitOpenAPI(schemas []keyspaceSchema, opts generateOptions) ([]generatedFile, error) {
+	var files []generatedFile
+
+	for _, schema := range schemas {
+		mapper := typeMapper{}.withUserTypes(schema.Types, "")
+
+		components := make(map[string]interface{})
+		owners := make(map[string]string)
+		addComponent := func(name string, owner string, component interface{}) error {
+			if previous, ok := owners[name]; ok {
+				return fmt.Errorf("keyspace %s: %s and %s are both components.schemas %s in OpenAPI", schema.Name, previous, owner, name)
+			}
+			owners[name] = owner
+			components[name] = component
+			return nil
+		}
+
+		for _, udt := range schema.Types {
+			properties := make(map[string]interface{})
+			for _, field := range udt.Fields {
+				property, err := cqlToOpenAPI(field.Type, mapper)
+				if err != nil {
+					return nil, fmt.Errorf("type %s field %s: %w", udt.Name, field.Name, err)
+				}
+				properties[field.Name] = property
+			}
+			component := map[string]interface{}{"type": "object", "properties": properties}
+			if err := addComponent(mapper.UDTs[udt.Name]+openAPIUDTSuffix, "type "+udt.Name, component); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, table := range schema.Tables {
+			properties := make(map[string]interface{})
+			var required []string
+
+			for _, col := range table.Columns {
+				property, err := cqlToOpenAPI(col.Type, mapper)
+				if err != nil {
+					return nil, fmt.Errorf("table %s column %s: %w", table.Name, col.Name, err)
+				}
+
+				properties[col.Name] = property
+				if isPrimaryKey(col) {
+					required = append(required, col.Name)
+				}
+			}
+
+			component := map[string]interface{}{"type": "object", "properties": properties}
+			if len(required) > 0 {
+				component["required"] = required
+			}
+			if err := addComponent(toPascal(table.Name), "table "+table.Name, component); err != nil {
+				return nil, err
+			}
+		}
+
+		document := map[string]interface{}{
+			"openapi": openAPIVersion,
+			"info": map[string]interface{}{
+				"title":       schema.Name,
+				"version":     "1.0.0",
+				"description": generatedMarker,
+			},
+			"paths":      map[string]interface{}{},
+			"components": map[string]interface{}{"schemas": components},
+		}
+
+		content, err := marshalJSON(document)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, generatedFile{Path: sanitizePathComponent(schema.Name) + "/openapi.json", Content: content})
+	}
+
+	return files, nil
+}
+
+// cqlToOpenAPI maps a CQL type to an OpenAPI 3.0 schema object. User-defined
+// types are referenced from components.schemas by struct name and
+// openAPIUDTSuffix.
+func cqlToOpenAPI(cqlType string, mapper typeMapper) (map[string]interface{}, error) {
+	node, err := mapper.parseCQLType(cqlType)
+	if err != nil {
+		return nil, err
+	}
+	return openAPIType(node, mapper)
+}
+
+func openAPIType(node cqlTypeNode, mapper typeMapper) (map[string]interface{}, error) {
+	switch {
+	case (node.Name == "list" || node.Name == "set") && len(node.Params) == 1:
+		items, err := openAPIType(node.Params[0], mapper)
+		if err != nil {
+			return nil, err
+		}
+		schema := map[string]interface{}{"type": "array", "items": items}
+		if node.Name == "set" {
+			schema["uniqueItems"] = true
+		}
+		return schema, nil
+
+	case node.Name == "map" && len(node.Params) == 2:
+		values, err := openAPIType(node.Params[1], mapper)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+
+	case len(node.Params) > 0:
+		return nil, fmt.Errorf("unknown CQL type: %s", node.Name)
+	}
+
+	if node.UDT != "" {
+		return map[string]interface{}{"$ref": "#/components/schemas/" + mapper.UDTs[node.UDT] + openAPIUDTSuffix}, nil
+	}
+
+	switch node.Name {
+	case "boolean":
+		return map[string]interface{}{"type": "boolean"}, nil
+	case "tinyint", "smallint", "int":
+		return map[string]interface{}{"type": "integer", "format": "int32"}, nil
+	case "bigint", "counter":
+		return map[string]interface{}{"type": "integer", "format": "int64"}, nil
+	case "varint":
+		return map[string]interface{}{"type": "integer"}, nil
+	case "time":
+		// Nanoseconds since midnight.
+		return map[string]interface{}{"type": "integer", "format": "int64"}, nil
+	case "float":
+		return map[string]interface{}{"type": "number", "format": "float"}, nil
+	case "double":
+		return map[string]interface{}{"type": "number", "format": "double"}, nil
+	case "decimal":
+		return map[string]interface{}{"type": "number"}, nil
+	case "text", "varchar", "ascii", "inet", "duration":
+		return map[string]interface{}{"type": "string"}, nil
+	case "uuid", "timeuuid":
+		return map[string]interface{}{"type": "string", "format": "uuid"}, nil
+	case "timestamp":
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	case "date":
+		return map[string]interface{}{"type": "string", "format": "date"}, nil
+	case "blob":
+		return map[string]interface{}{"type": "string", "format": "byte"}, nil
+	default:
+		return nil, fmt.Errorf("unknown CQL type: %s", node.Name)
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Options holds every setting of a generate run; each field has the meaning
+// of the flag of the same name. Zero values select the flag defaults, so an
+// Options literal naming only a keyspace is a complete configuration:
+// Connection.Host "localhost", Connection.Port 9042, Formats "go",
+// OutputDir "./models", Package "models", GocqlImport "github.com/gocql/gocql",
+// EmbedName "Audit", JSONCase "column", Indent "tab", LineEnding "lf",
+// FileMode "0644", DirMode "0755", BatchType "unlogged", EnumLimit 50 and
+// MaxTables 500. NullType defaults to "pointers" when Pointers is set and
+// "none" otherwise; IntType defaults to "strict" when StrictInt is set and
+// "exact" otherwise.
+type Options struct {
+	Connection ConnectionOptions
+	Selection  SchemaFlags
+
+	// Schema, when set, is a schema-json document read instead of
+	// connecting to Connection; -schemaStdin sets it to os.Stdin.
+	Schema io.Reader
+
+	// Formats is a comma-separated list of output formats.
+	Formats    string
+	OutputDir  string
+	OutputFile string
+	Package    string
+	HeaderFile string
+	BuildTags  string
+	Indent     string
+	NoFormat   bool
+	SplitFiles bool
+	LineEnding string
+
+	// FileMode and DirMode are the octal permissions of written files and
+	// created directories.
+	FileMode string
+	DirMode  string
+
+	// InitModule, when set, is the module path of a go.mod written next to
+	// the output if none exists yet.
+	InitModule string
+
+	GocqlImport  string
+	Gocqlx       bool
+	IntType      string
+	StrictInt    bool
+	OrderedMaps  bool
+	JSONCase     string
+	NoJSON       bool
+	ExtraTags    string
+	NullType     string
+	Pointers     bool
+	OmitEmpty    bool
+	ValidateTags bool
+	TypeComments bool
+	DocComments  bool
+
+	// PointerColumns lists table.column entries to make pointers, or with
+	// a ! prefix to keep plain, whatever NullType says.
+	PointerColumns string
+
+	// FieldNamesFile is a file of table.column entries and the Go field
+	// names they get instead of the derived ones.
+	FieldNamesFile string
+
+	Embed              string
+	EmbedName          string
+	FieldMap           bool
+	Registry           bool
+	RoutingKey         bool
+	PartitionKeyStruct bool
+	IterWrapper        bool
+	Insert             bool
+	Methods            bool
+	IndexMethods       bool
+	BatchHelpers       bool
+	BatchType          string
+	Fingerprint        bool
+	Accessors          bool
+	PrefixKeyspace     bool
+
+	// JSONEmptyCollections adds MarshalJSON methods that write nil
+	// collections as [] and {}.
+	JSONEmptyCollections bool
+
+	// UDTMarshalers adds MarshalUDT and UnmarshalUDT methods to the
+	// user-defined type structs.
+	UDTMarshalers bool
+
+	// Constructors generates New<Table> functions; Defaults maps table names
+	// to the column values those functions seed, and implies Constructors.
+	Constructors bool
+	Defaults     map[string]map[string]string
+
+	// Enums is a comma-separated table.column list, and EnumLimit the most
+	// distinct values such a column may have; zero selects 50.
+	// ColumnOrders maps table names to their pinned leading columns.
+	Enums        string
+	EnumLimit    int
+	ColumnOrders map[string][]string
+
+	SkipBadColumns bool
+
+	// MaxTables aborts the run when a keyspace has more tables than this; a
+	// negative value disables the limit.
+	MaxTables int
+
+	// SelfTest scans a real row of the -table table into each generated
+	// field type before generating, failing the run on mismatches.
+	SelfTest bool
+
+	Diff             bool
+	Clean            bool
+	Incremental      bool
+	Force            bool
+	MetricsJSON      string
+	PartialOnTimeout bool
+
+	// PostProcess, when set, runs on every generated file after the format
+	// pass and before -diff, writing or GenerateToBuffer returns. It has no
+	// flag.
+	PostProcess PostProcessFunc
+}
+
+// PostProcessFunc receives the path of a generated file, relative to
+// OutputDir or equal to OutputFile, and its content, and returns the content
+// to use in its place. Files are passed in output order, before line
+// endings are converted; an error aborts the run before anything is written.
+// The schema hash cache cannot see what a hook does, so runs with a hook
+// always regenerate.
+type PostProcessFunc func(path string, content []byte) ([]byte, error)
+
+// postProcess applies o.PostProcess to files in place.
+func (o Options) postProcess(files []generatedFile) error {
+	if o.PostProcess == nil {
+		return nil
+	}
+	for i := range files {
+		content, err := o.PostProcess(files[i].Path, files[i].Content)
+		if err != nil {
+			return fmt.Errorf("post-processing %s: %w", files[i].Path, err)
+		}
+		files[i].Content = content
+	}
+	return nil
+}
+
+// withDefaults returns a copy of o with zero values replaced by defaults.
+func (o Options) withDefaults() Options {
+	setDefault := func(value *string, fallback string) {
+		if *value == "" {
+			*value = fallback
+		}
+	}
+
+	setDefault(&o.Connection.Host, "localhost")
+	setDefault(&o.Formats, "go")
+	setDefault(&o.OutputDir, "./models")
+	setDefault(&o.Package, "models")
+	setDefault(&o.GocqlImport, defaultGocqlImport)
+	setDefault(&o.EmbedName, "Audit")
+	setDefault(&o.JSONCase, "column")
+	setDefault(&o.Indent, "tab")
+	setDefault(&o.LineEnding, "lf")
+	setDefault(&o.FileMode, "0644")
+	setDefault(&o.DirMode, "0755")
+	setDefault(&o.BatchType, "unlogged")
+
+	// -pointers predates -nullType and selects its pointers mode.
+	if o.NullType == "" {
+		o.NullType = "none"
+		if o.Pointers {
+			o.NullType = "pointers"
+		}
+	}
+
+	// -strictInt selects the strict integer mapping.
+	if o.IntType == "" {
+		o.IntType = "exact"
+		if o.StrictInt {
+			o.IntType = "strict"
+		}
+	}
+
+	if o.Connection.Port == 0 {
+		o.Connection.Port = 9042
+	}
+	if o.EnumLimit == 0 {
+		o.EnumLimit = 50
+	}
+	if o.MaxTables == 0 {
+		o.MaxTables = 500
+	}
+	return o
+}
+
+// Validate reports the first setting that is invalid or conflicts with
+// another. It checks o as given, so call it on o.withDefaults().
+func (o Options) Validate() error {
+	if o.Selection.Keyspaces == "" && o.Selection.KeyspaceRegex == "" && o.Schema == nil {
+		return fmt.Errorf("keyspace name is required (use -keyspace or -keyspaceRegex)")
+	}
+
+	if o.Schema != nil && o.Enums != "" {
+		return fmt.Errorf("-enums queries the cluster and cannot be combined with -schemaStdin (the document's enum_values are used instead)")
+	}
+
+	if o.SelfTest && (o.Selection.Table == "" || o.Schema != nil) {
+		return fmt.Errorf("-selfTest reads table data and needs -table and a cluster connection")
+	}
+
+	if _, err := parseFormats(o.Formats); err != nil {
+		return err
+	}
+
+	if o.OutputFile != "" && o.Clean {
+		return fmt.Errorf("-clean cannot be combined with -outputFile")
+	}
+
+	if _, err := o.outputModes(); err != nil {
+		return err
+	}
+
+	if o.Incremental && !o.SplitFiles {
+		return fmt.Errorf("-incremental tracks per-table files and needs -splitFiles")
+	}
+
+	if o.Incremental && o.Clean {
+		return fmt.Errorf("-incremental removes the files of dropped tables itself and cannot be combined with -clean")
+	}
+
+	if o.InitModule != "" {
+		if err := validateModulePath(o.InitModule); err != nil {
+			return err
+		}
+	}
+
+	if err := validatePackageName(o.Package); err != nil {
+		return err
+	}
+
+	if err := validateJSONCase(o.JSONCase); err != nil {
+		return err
+	}
+
+	if _, err := parseExtraTags(o.ExtraTags); err != nil {
+		return err
+	}
+
+	if o.NoJSON && o.OmitEmpty {
+		return fmt.Errorf("-omitempty only affects json tags and cannot be combined with -noJSON")
+	}
+
+	if err := validateNullType(o.NullType); err != nil {
+		return err
+	}
+
+	if o.Pointers && o.NullType != "pointers" {
+		return fmt.Errorf("-pointers cannot be combined with -nullType %s", o.NullType)
+	}
+
+	if _, err := parsePointerColumns(o.PointerColumns); err != nil {
+		return err
+	}
+
+	if o.Connection.SerialConsistency != "" {
+		if _, err := parseSerialConsistency(o.Connection.SerialConsistency); err != nil {
+			return err
+		}
+	}
+
+	if err := validateIntType(o.IntType); err != nil {
+		return err
+	}
+
+	if o.StrictInt && o.IntType != "strict" {
+		return fmt.Errorf("-strictInt cannot be combined with -intType %s", o.IntType)
+	}
+
+	if err := validateBatchType(o.BatchType); err != nil {
+		return err
+	}
+
+	if o.BuildTags != "" {
+		if err := validateBuildTags(o.BuildTags); err != nil {
+			return err
+		}
+	}
+
+	if _, err := parseIndent(o.Indent); err != nil {
+		return err
+	}
+
+	if o.LineEnding != "lf" && o.LineEnding != "crlf" {
+		return fmt.Errorf("unknown line ending %q: expected lf or crlf", o.LineEnding)
+	}
+
+	for _, spec := range splitList(o.Enums) {
+		if !strings.Contains(spec, ".") {
+			return fmt.Errorf("invalid -enums entry %q: expected table.column", spec)
+		}
+	}
+
+	if o.EnumLimit < 1 {
+		return fmt.Errorf("-enumLimit must be at least 1")
+	}
+
+	return nil
+}
+
+// generateOptions derives the settings the emitters use. o must be valid.
+func (o Options) generateOptions() (generateOptions, error) {
+	indentUnit, err := parseIndent(o.Indent)
+	if err != nil {
+		return generateOptions{}, err
+	}
+
+	pointerColumns, err := parsePointerColumns(o.PointerColumns)
+	if err != nil {
+		return generateOptions{}, err
+	}
+
+	extraTags, err := parseExtraTags(o.ExtraTags)
+	if err != nil {
+		return generateOptions{}, err
+	}
+
+	var header string
+	if o.HeaderFile != "" {
+		content, err := os.ReadFile(o.HeaderFile)
+		if err != nil {
+			return generateOptions{}, fmt.Errorf("reading -headerFile: %w", err)
+		}
+		header = commentHeader(string(content))
+	}
+
+	var fieldNames map[string]map[string]string
+	if o.FieldNamesFile != "" {
+		fieldNames, err = loadFieldNames(o.FieldNamesFile)
+		if err != nil {
+			return generateOptions{}, fmt.Errorf("reading -fieldNames: %w", err)
+		}
+	}
+
+	types := typeMapper{IntType: o.IntType}
+	if o.OrderedMaps {
+		types.OrderedMap = orderedMapTypeName
+	}
+
+	return generateOptions{
+		Struct: structOptions{
+			DBTags:       o.Gocqlx,
+			NullType:     o.NullType,
+			OmitEmpty:    o.OmitEmpty,
+			ValidateTags: o.ValidateTags,
+			TypeComments: o.TypeComments,
+			JSONCase:     o.JSONCase,
+			NoJSON:       o.NoJSON,
+			ExtraTags:    extraTags,
+			Types:        types,
+		},
+		Gocqlx:               o.Gocqlx,
+		FieldMap:             o.FieldMap,
+		EmbedColumns:         splitList(o.Embed),
+		EmbedName:            o.EmbedName,
+		GocqlImport:          o.GocqlImport,
+		BuildTags:            o.BuildTags,
+		Registry:             o.Registry,
+		Indent:               indentUnit,
+		NoFormat:             o.NoFormat,
+		SplitFiles:           o.SplitFiles,
+		RoutingKey:           o.RoutingKey,
+		PartitionKeyStruct:   o.PartitionKeyStruct,
+		IterWrapper:          o.IterWrapper,
+		Insert:               o.Insert,
+		Methods:              o.Methods || o.IndexMethods,
+		IndexMethods:         o.IndexMethods,
+		BatchHelpers:         o.BatchHelpers,
+		BatchType:            o.BatchType,
+		Fingerprint:          o.Fingerprint,
+		PointerColumns:       pointerColumns,
+		FieldNames:           fieldNames,
+		Constructors:         o.Constructors || len(o.Defaults) > 0,
+		Defaults:             o.Defaults,
+		Package:              o.Package,
+		PrefixKeyspace:       o.PrefixKeyspace,
+		DocComments:          o.DocComments,
+		Accessors:            o.Accessors,
+		JSONEmptyCollections: o.JSONEmptyCollections,
+		UDTMarshalers:        o.UDTMarshalers,
+		Header:               header,
+	}, nil
+}
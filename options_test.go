@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnumLimit(t *testing.T) {
+	opts := Options{Selection: SchemaFlags{Keyspaces: "shop"}}.withDefaults()
+	if opts.EnumLimit != 50 {
+		t.Errorf("a zero EnumLimit defaults to %d, want 50", opts.EnumLimit)
+	}
+	if err := opts.Validate(); err != nil {
+		t.Errorf("the default EnumLimit is invalid: %v", err)
+	}
+
+	// runGenerate passes -enumLimit 0 on as -1.
+	for _, limit := range []int{-1, -50} {
+		opts := Options{Selection: SchemaFlags{Keyspaces: "shop"}, EnumLimit: limit}.withDefaults()
+		err := opts.Validate()
+		if err == nil || !strings.Contains(err.Error(), "-enumLimit must be at least 1") {
+			t.Errorf("EnumLimit %d: Validate returned %v", limit, err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// orderedMapTypeName is the generated ordered map type, after the keyspace
+// prefix, that -orderedMaps maps CQL maps to.
+const orderedMapTypeName = "OrderedMap"
+
+// isOrderedMapType reports whether goType is an instance of the generated
+// ordered map, which is a slice and so neither comparable nor a struct.
+func isOrderedMapType(goType string) bool {
+	return strings.Contains(goType, orderedMapTypeName+"[")
+}
+
+// usesOrderedMap reports whether any declaration refers to the ordered map
+// type name, so the helper is only emitted where it is needed.
+func usesOrderedMap(name string, declarations []string) bool {
+	if name == "" {
+		return false
+	}
+	pattern := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\[`)
+	for _, declaration := range declarations {
+		if pattern.MatchString(declaration) {
+			return true
+		}
+	}
+	return false
+}
+
+// generateOrderedMap emits the ordered map type: a slice of key and value
+// entries that implements gocql.Marshaler and gocql.Unmarshaler by reading
+// and writing the map wire format itself, keeping entries in the order the
+// server sends them.
+func generateOrderedMap(name string) string {
+	return fmt.Sprintf(`// %[1]s holds a CQL map with its entries in the order the server sends
+// them, which is sorted by key, where a Go map would lose the order. It scans
+// and binds like a native map.
+type %[1]s[K, V any] []%[1]sEntry[K, V]
+
+// %[1]sEntry is one key and value of a %[1]s.
+type %[1]sEntry[K, V any] struct {
+    Key   K
+    Value V
+}
+
+// UnmarshalCQL implements gocql.Unmarshaler.
+func (m *%[1]s[K, V]) UnmarshalCQL(info gocql.TypeInfo, data []byte) error {
+    mapInfo, ok := info.(gocql.CollectionType)
+    if !ok || mapInfo.Type() != gocql.TypeMap {
+        return fmt.Errorf("cannot unmarshal %%s into %[1]s", info.Type())
+    }
+    if data == nil {
+        *m = nil
+        return nil
+    }
+
+    // Protocol v3 and later frame sizes as int32, earlier versions as uint16.
+    readSize := func() (int, error) {
+        if mapInfo.Version() > 2 {
+            if len(data) < 4 {
+                return 0, fmt.Errorf("unmarshal %[1]s: unexpected eof")
+            }
+            size := int(int32(binary.BigEndian.Uint32(data)))
+            data = data[4:]
+            return size, nil
+        }
+        if len(data) < 2 {
+            return 0, fmt.Errorf("unmarshal %[1]s: unexpected eof")
+        }
+        size := int(binary.BigEndian.Uint16(data))
+        data = data[2:]
+        return size, nil
+    }
+    readItem := func() ([]byte, error) {
+        size, err := readSize()
+        if err != nil || size < 0 {
+            return nil, err
+        }
+        if len(data) < size {
+            return nil, fmt.Errorf("unmarshal %[1]s: unexpected eof")
+        }
+        item := data[:size]
+        data = data[size:]
+        return item, nil
+    }
+
+    count, err := readSize()
+    if err != nil {
+        return err
+    }
+
+    entries := make(%[1]s[K, V], 0)
+    for i := 0; i < count; i++ {
+        var entry %[1]sEntry[K, V]
+
+        key, err := readItem()
+        if err != nil {
+            return err
+        }
+        if err := gocql.Unmarshal(mapInfo.Key, key, &entry.Key); err != nil {
+            return err
+        }
+
+        value, err := readItem()
+        if err != nil {
+            return err
+        }
+        if err := gocql.Unmarshal(mapInfo.Elem, value, &entry.Value); err != nil {
+            return err
+        }
+
+        entries = append(entries, entry)
+    }
+
+    *m = entries
+    return nil
+}
+
+// MarshalCQL implements gocql.Marshaler.
+func (m %[1]s[K, V]) MarshalCQL(info gocql.TypeInfo) ([]byte, error) {
+    mapInfo, ok := info.(gocql.CollectionType)
+    if !ok || mapInfo.Type() != gocql.TypeMap {
+        return nil, fmt.Errorf("cannot marshal %[1]s into %%s", info.Type())
+    }
+    if m == nil {
+        return nil, nil
+    }
+
+    var buf []byte
+    writeSize := func(size int) {
+        if mapInfo.Version() > 2 {
+            buf = binary.BigEndian.AppendUint32(buf, uint32(size))
+            return
+        }
+        buf = binary.BigEndian.AppendUint16(buf, uint16(size))
+    }
+    writeItem := func(item []byte) {
+        if item == nil && mapInfo.Version() > 2 {
+            writeSize(-1)
+            return
+        }
+        writeSize(len(item))
+        buf = append(buf, item...)
+    }
+
+    writeSize(len(m))
+    for _, entry := range m {
+        key, err := gocql.Marshal(mapInfo.Key, entry.Key)
+        if err != nil {
+            return nil, err
+        }
+        writeItem(key)
+
+        value, err := gocql.Marshal(mapInfo.Elem, entry.Value)
+        if err != nil {
+            return nil, err
+        }
+        writeItem(value)
+    }
+
+    return buf, nil
+}
+`, name)
+}
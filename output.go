@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// outputModes are the permissions of the files a run writes and of the
+// directories it creates.
+type outputModes struct {
+	File fs.FileMode
+	Dir  fs.FileMode
+}
+
+// parseFileMode parses the octal permission bits of -fileMode or -dirMode.
+// The owner must keep read and write access, and execute access to
+// directories, so later runs can update the output.
+func parseFileMode(flagName string, value string, ownerBits fs.FileMode) (fs.FileMode, error) {
+	bits, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || bits > 0777 {
+		return 0, fmt.Errorf("invalid -%s %q: expected octal permission bits such as 0644", flagName, value)
+	}
+
+	mode := fs.FileMode(bits)
+	if mode&ownerBits != ownerBits {
+		return 0, fmt.Errorf("invalid -%s %s: the owner needs at least %#o", flagName, value, uint32(ownerBits))
+	}
+	return mode, nil
+}
+
+// outputModes returns the parsed -fileMode and -dirMode.
+func (o Options) outputModes() (outputModes, error) {
+	file, err := parseFileMode("fileMode", o.FileMode, 0600)
+	if err != nil {
+		return outputModes{}, err
+	}
+	dir, err := parseFileMode("dirMode", o.DirMode, 0700)
+	if err != nil {
+		return outputModes{}, err
+	}
+	return outputModes{File: file, Dir: dir}, nil
+}
+
+// isGeneratedFile reports whether the file at filePath carries the generated
+// marker before its package clause. The marker may sit in a comment or, for
+// JSON output, in a "$comment" field.
+func isGeneratedFile(filePath string) (bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.Contains(line, generatedMarker) {
+			return true, nil
+		}
+		if strings.HasPrefix(line, "package ") {
+			return false, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// writeGeneratedFiles writes files to disk, refusing to overwrite any existing
+// file that was not generated by this tool. With clean, generated files left
+// over in the output directories from earlier runs are removed. With force,
+// existing files are replaced regardless of their origin or permissions.
+// Each file is written to a temporary file that guard removes if the run is
+// interrupted, then renamed into place, so no file is ever left half written.
+// Files get modes.File and new directories modes.Dir.
+func writeGeneratedFiles(files []generatedFile, clean bool, force bool, modes outputModes, guard *runGuard) error {
+	written := make(map[string]bool)
+	dirs := make(map[string]bool)
+
+	// Check every target up front so a refusal leaves no partial output.
+	for _, file := range files {
+		if force {
+			continue
+		}
+
+		generated, err := isGeneratedFile(file.Path)
+		if errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("cannot read %s: permission denied (use -force to replace it)", file.Path)
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("checking %s: %w", file.Path, err)
+		}
+		if err == nil && !generated {
+			return fmt.Errorf("refusing to overwrite %s: it was not generated by go-cql-scaffold", file.Path)
+		}
+	}
+
+	for _, file := range files {
+		dir := filepath.Dir(file.Path)
+		if err := os.MkdirAll(dir, modes.Dir); err != nil {
+			return fmt.Errorf("creating directory: %w", err)
+		}
+
+		// Renaming replaces read-only files too, so without force the old
+		// file must itself be writable.
+		if !force {
+			existing, err := os.OpenFile(file.Path, os.O_WRONLY, 0)
+			if errors.Is(err, fs.ErrPermission) {
+				return fmt.Errorf("cannot write %s: permission denied (use -force to replace it)", file.Path)
+			}
+			if err == nil {
+				existing.Close()
+			}
+		}
+
+		if err := writeFileAtomic(file.Path, file.Content, modes.File, guard); err != nil {
+			return fmt.Errorf("writing to file: %w", err)
+		}
+
+		written[filepath.Clean(file.Path)] = true
+		dirs[dir] = true
+		fmt.Printf("Generated %s\n", file.Path)
+	}
+
+	if !clean {
+		return nil
+	}
+
+	for dir := range dirs {
+		stale, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			return err
+		}
+
+		for _, filePath := range stale {
+			if written[filepath.Clean(filePath)] {
+				continue
+			}
+
+			generated, err := isGeneratedFile(filePath)
+			if err != nil {
+				return fmt.Errorf("checking %s: %w", filePath, err)
+			}
+			if !generated {
+				continue
+			}
+
+			if err := os.Remove(filePath); err != nil {
+				return fmt.Errorf("removing %s: %w", filePath, err)
+			}
+			fmt.Printf("Removed %s\n", filePath)
+		}
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes content to a temporary file next to path and renames
+// it over path, with mode as its permissions.
+func writeFileAtomic(path string, content []byte, mode fs.FileMode, guard *runGuard) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	finish := guard.trackTempFile(temp.Name())
+	defer finish()
+
+	_, err = temp.Write(content)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
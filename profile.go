@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const defaultConfigFile = ".cqlscaffold.json"
+
+// scaffoldConfig is the -config file. Profiles hold named connection
+// settings, such as one per environment, selected with -profile.
+type scaffoldConfig struct {
+	Profiles map[string]connectionProfile `json:"profiles"`
+}
+
+// connectionProfile mirrors the connection flags. Zero values leave the
+// corresponding setting alone.
+type connectionProfile struct {
+	Host     string      `json:"host"`
+	Port     int         `json:"port"`
+	BindAddr string      `json:"bindAddr"`
+	Username string      `json:"username"`
+	Password string      `json:"password"`
+	TLS      *profileTLS `json:"tls"`
+}
+
+type profileTLS struct {
+	CA         string `json:"ca"`
+	Cert       string `json:"cert"`
+	Key        string `json:"key"`
+	SkipVerify bool   `json:"skipVerify"`
+}
+
+func loadConfig(filePath string) (scaffoldConfig, error) {
+	var config scaffoldConfig
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return config, err
+	}
+
+	if err := json.Unmarshal(content, &config); err != nil {
+		return config, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return config, nil
+}
+
+// applyConnectionDefaults fills connection settings that were not given as
+// flags, first from cqlshrc and then from the selected profile, so explicit
+// flags win over the profile and the profile wins over cqlshrc.
+func applyConnectionDefaults(fs *flag.FlagSet, opts *ConnectionOptions) error {
+	if err := applyCqlshrc(fs, opts); err != nil {
+		return err
+	}
+	return applyProfile(fs, opts)
+}
+
+// applyProfile copies the -profile entry of the -config file into settings
+// whose flags were not given explicitly.
+func applyProfile(fs *flag.FlagSet, opts *ConnectionOptions) error {
+	if opts.Profile == "" {
+		return nil
+	}
+
+	filePath := opts.Config
+	if filePath == "" {
+		filePath = defaultConfigFile
+	}
+
+	config, err := loadConfig(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("-profile %s: config file %s does not exist", opts.Profile, filePath)
+	}
+	if err != nil {
+		return err
+	}
+
+	profile, ok := config.Profiles[opts.Profile]
+	if !ok {
+		var names []string
+		for name := range config.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("-profile %s: no such profile in %s (available: %s)", opts.Profile, filePath, strings.Join(names, ", "))
+	}
+
+	explicit := explicitFlags(fs)
+
+	setString := func(flagName string, target *string, value string) {
+		if value != "" && !explicit[flagName] {
+			*target = value
+		}
+	}
+
+	setString("host", &opts.Host, profile.Host)
+	setString("bindAddr", &opts.BindAddr, profile.BindAddr)
+	setString("username", &opts.Username, profile.Username)
+	if profile.Username != "" && !explicit["password"] {
+		// Never pair the profile's user with a password from cqlshrc.
+		opts.Password = profile.Password
+	}
+	if profile.Port != 0 && !explicit["port"] {
+		opts.Port = profile.Port
+	}
+
+	if profile.TLS != nil {
+		if !explicit["tls"] {
+			opts.TLS = true
+		}
+		setString("tlsCA", &opts.TLSCA, profile.TLS.CA)
+		setString("tlsCert", &opts.TLSCert, profile.TLS.Cert)
+		setString("tlsKey", &opts.TLSKey, profile.TLS.Key)
+		if profile.TLS.SkipVerify && !explicit["tlsSkipVerify"] {
+			opts.TLSSkipVerify = true
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// fetchTables lists the tables of a keyspace with the comment each was
+// given WITH comment, in one query. When table is set only that table's row
+// is read, and it is an error for the keyspace not to have it.
+func fetchTables(session *gocql.Session, keyspace string, table string) ([]string, map[string]string, error) {
+	var tableName, comment string
+	var tableNames []string
+	comments := make(map[string]string)
+
+	query := "SELECT table_name, comment FROM system_schema.tables WHERE keyspace_name = ?"
+	args := []interface{}{keyspace}
+	if table != "" {
+		query += " AND table_name = ?"
+		args = append(args, table)
+	}
+	iter := session.Query(query, args...).Iter()
+
+	for iter.Scan(&tableName, &comment) {
+		tableNames = append(tableNames, tableName)
+		comments[tableName] = comment
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	if table != "" && len(tableNames) == 0 {
+		return nil, nil, fmt.Errorf("table %s not found in keyspace %s", table, keyspace)
+	}
+	return tableNames, comments, nil
+}
+
+// fetchIndexes returns the secondary indexes of a table, sorted by name.
+func fetchIndexes(session *gocql.Session, keyspace string, tableName string) ([]indexSchema, error) {
+	query := "SELECT index_name, kind, options FROM system_schema.indexes WHERE keyspace_name = ? AND table_name = ?"
+	iter := session.Query(query, keyspace, tableName).Iter()
+
+	var index indexSchema
+	var options map[string]string
+	var indexes []indexSchema
+
+	for iter.Scan(&index.Name, &index.Kind, &options) {
+		index.Target = options["target"]
+		indexes = append(indexes, index)
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+	return indexes, nil
+}
+
+// fetchKeyspaceNames lists every keyspace except the system keyspaces.
+func fetchKeyspaceNames(session *gocql.Session) ([]string, error) {
+	var keyspaceName string
+	var keyspaceNames []string
+
+	iter := session.Query("SELECT keyspace_name FROM system_schema.keyspaces").Iter()
+
+	for iter.Scan(&keyspaceName) {
+		if keyspaceName != "system" && !strings.HasPrefix(keyspaceName, "system_") {
+			keyspaceNames = append(keyspaceNames, keyspaceName)
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keyspaceNames)
+	return keyspaceNames, nil
+}
+
+// clusterInfo is what system.local reports about the node a session reached.
+// ProtocolVersion is the highest native protocol version the node supports.
+type clusterInfo struct {
+	ClusterName     string
+	DataCenter      string
+	ReleaseVersion  string
+	ProtocolVersion string
+}
+
+// pingCluster runs a trivial query against system.local to confirm the
+// session can actually read from the cluster before introspection starts.
+func pingCluster(session *gocql.Session) (clusterInfo, error) {
+	var info clusterInfo
+
+	query := "SELECT cluster_name, data_center, release_version, native_protocol_version FROM system.local"
+	err := session.Query(query).Scan(&info.ClusterName, &info.DataCenter, &info.ReleaseVersion, &info.ProtocolVersion)
+
+	return info, err
+}
+
+type column struct {
+	Name            string `json:"name"`
+	Type            string `json:"type"`
+	Kind            string `json:"kind"`
+	Position        int    `json:"position"`
+	ClusteringOrder string `json:"clustering_order"`
+}
+
+type tableSchema struct {
+	Name    string   `json:"name"`
+	Comment string   `json:"comment,omitempty"`
+	Columns []column `json:"columns"`
+
+	// EnumValues holds the distinct values observed for columns requested
+	// with -enums, keyed by column name.
+	EnumValues map[string][]string `json:"enum_values,omitempty"`
+
+	Indexes []indexSchema `json:"indexes,omitempty"`
+}
+
+// indexSchema is a secondary index as system_schema.indexes records it.
+// Target is the index's target option: a column name, possibly quoted, or
+// keys(col), values(col), entries(col) or full(col) for collections.
+type indexSchema struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+}
+
+type udtField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// udtSchema describes a user-defined type with its fields in definition order.
+type udtSchema struct {
+	Name   string     `json:"name"`
+	Fields []udtField `json:"fields"`
+}
+
+type keyspaceSchema struct {
+	Name   string        `json:"name"`
+	Types  []udtSchema   `json:"types,omitempty"`
+	Tables []tableSchema `json:"tables"`
+}
+
+func fetchUserTypes(session *gocql.Session, keyspace string) ([]udtSchema, error) {
+	query := "SELECT * FROM system_schema.types WHERE keyspace_name = ?"
+	iter := session.Query(query, keyspace).Iter()
+
+	var types []udtSchema
+
+	for row := make(map[string]interface{}); iter.MapScan(row); row = make(map[string]interface{}) {
+		typeName := rowString(row, "type_name")
+		fieldNames, fieldTypes := rowStrings(row, "field_names"), rowStrings(row, "field_types")
+
+		udt := udtSchema{Name: typeName}
+		for i, name := range fieldNames {
+			if i < len(fieldTypes) {
+				fieldType := normalizeFetchedType(fmt.Sprintf("field %s.%s", typeName, name), fieldTypes[i])
+				udt.Fields = append(udt.Fields, udtField{Name: name, Type: fieldType})
+			}
+		}
+		types = append(types, udt)
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	return types, nil
+}
+
+// fetchKeyspaceSchema introspects every table of a keyspace that is not
+// excluded. Tables whose columns cannot be fetched are logged and left out.
+// When table is set only that table is read, without listing the others, and
+// it is an error for it not to exist; table exclusions do not apply to it.
+func fetchKeyspaceSchema(session *gocql.Session, keyspace string, table string, excluded exclusions) (keyspaceSchema, error) {
+	schema := keyspaceSchema{Name: keyspace}
+
+	types, err := fetchUserTypes(session, keyspace)
+	if err != nil {
+		return schema, fmt.Errorf("fetching user-defined types: %w", err)
+	}
+	schema.Types = types
+
+	tableNames, comments, err := fetchTables(session, keyspace, table)
+	if err != nil {
+		return schema, err
+	}
+
+	for _, tableName := range tableNames {
+		if table == "" && excluded.excludesTable(tableName) {
+			continue
+		}
+
+		columns, err := fetchColumnsSettled(session, keyspace, tableName)
+		if err != nil && table != "" {
+			return schema, fmt.Errorf("fetching column definitions for table %s: %w", tableName, err)
+		}
+		if err != nil {
+			log.Printf("Error fetching column definitions for table %s: %v", tableName, err)
+			continue
+		}
+		if len(columns) == 0 && table != "" {
+			return schema, fmt.Errorf("table %s.%s has no columns after %d attempts (a concurrent schema change?)", keyspace, tableName, emptyColumnAttempts)
+		}
+		if len(columns) == 0 {
+			log.Printf("Warning: table %s.%s has no columns after %d attempts (a concurrent schema change?); skipping it", keyspace, tableName, emptyColumnAttempts)
+			continue
+		}
+
+		kept := columns[:0]
+		for _, col := range columns {
+			if !excluded.excludesColumn(tableName, col.Name) {
+				kept = append(kept, col)
+			}
+		}
+		columns = kept
+
+		indexes, err := fetchIndexes(session, keyspace, tableName)
+		if err != nil {
+			log.Printf("Error fetching indexes for table %s: %v", tableName, err)
+		}
+
+		schema.Tables = append(schema.Tables, tableSchema{Name: tableName, Comment: comments[tableName], Columns: columns, Indexes: indexes})
+	}
+
+	return schema, nil
+}
+
+// emptyColumnAttempts bounds how often fetchColumnsSettled reads the columns
+// of a table that has none; emptyColumnDelay is the wait before each retry,
+// multiplied by the attempt number.
+const (
+	emptyColumnAttempts = 3
+	emptyColumnDelay    = 200 * time.Millisecond
+)
+
+// fetchColumnsSettled fetches the columns of a table, retrying an empty
+// result a few times. A table listed in system_schema.tables can briefly have
+// no columns while a concurrent migration writes its definition.
+func fetchColumnsSettled(session *gocql.Session, keyspace string, tableName string) ([]column, error) {
+	for attempt := 1; ; attempt++ {
+		columns, err := fetchColumnDefinitions(session, keyspace, tableName)
+		if err != nil || len(columns) > 0 || attempt == emptyColumnAttempts {
+			return columns, err
+		}
+		time.Sleep(time.Duration(attempt) * emptyColumnDelay)
+	}
+}
+
+func fetchColumnDefinitions(session *gocql.Session, keyspace string, tableName string) ([]column, error) {
+	query := "SELECT * FROM system_schema.columns WHERE keyspace_name = ? AND table_name = ?"
+	iter := session.Query(query, keyspace, tableName).Iter()
+
+	var columns []column
+
+	for row := make(map[string]interface{}); iter.MapScan(row); row = make(map[string]interface{}) {
+		col := column{
+			Name:            rowString(row, "column_name"),
+			Type:            rowString(row, "type"),
+			Kind:            rowString(row, "kind"),
+			Position:        rowInt(row, "position"),
+			ClusteringOrder: rowString(row, "clustering_order"),
+		}
+		col.Type = normalizeFetchedType(fmt.Sprintf("column %s.%s", tableName, col.Name), col.Type)
+		columns = append(columns, col)
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	sortColumns(columns)
+	return columns, nil
+}
+
+// rowString returns a text column of a schema table row read with MapScan,
+// or "" when the row has no such column. Reading rows by name means columns a
+// newer server adds are ignored and ones an older server lacks are zero
+// values instead of failing the scan.
+func rowString(row map[string]interface{}, name string) string {
+	value, _ := row[name].(string)
+	return value
+}
+
+// rowInt is rowString for integer columns of any width.
+func rowInt(row map[string]interface{}, name string) int {
+	switch value := row[name].(type) {
+	case int:
+		return value
+	case int8:
+		return int(value)
+	case int16:
+		return int(value)
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	}
+	return 0
+}
+
+// rowStrings is rowString for list<text> columns.
+func rowStrings(row map[string]interface{}, name string) []string {
+	value, _ := row[name].([]string)
+	return value
+}
+
+// fetchEnumValues reads up to limit+1 distinct values of a column so callers
+// can tell when the limit is exceeded. CQL only allows SELECT DISTINCT on
+// partition key columns; other columns make the query fail.
+func fetchEnumValues(session *gocql.Session, keyspace string, tableName string, columnName string, limit int) ([]string, error) {
+	query := fmt.Sprintf("SELECT DISTINCT %s FROM %s.%s LIMIT %d", quoteIdentifier(columnName), quoteIdentifier(keyspace), quoteIdentifier(tableName), limit+1)
+	iter := session.Query(query).Iter()
+
+	var value string
+	var values []string
+
+	for iter.Scan(&value) {
+		values = append(values, value)
+	}
+
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(values)
+	return values, nil
+}
+
+// quoteIdentifier quotes a CQL identifier so it is used with its exact case.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sortColumns orders columns the way cqlsh describes a table: partition key
+// columns first, then clustering columns, both by position, then the rest by name.
+func sortColumns(columns []column) {
+	rank := func(kind string) int {
+		switch kind {
+		case "partition_key":
+			return 0
+		case "clustering":
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	sort.SliceStable(columns, func(i, j int) bool {
+		ri, rj := rank(columns[i].Kind), rank(columns[j].Kind)
+		if ri != rj {
+			return ri < rj
+		}
+		if ri < 2 {
+			return columns[i].Position < columns[j].Position
+		}
+		return columns[i].Name < columns[j].Name
+	})
+}
+
+// pinColumnOrder moves the named columns to the front, in the given order,
+// and keeps every other column in its existing order after them.
+func pinColumnOrder(columns []column, order []string) ([]column, error) {
+	pinned := make([]column, 0, len(columns))
+	for _, name := range order {
+		col, ok := findColumn(columns, name)
+		if !ok {
+			return nil, fmt.Errorf("column %s does not exist", name)
+		}
+		if _, dup := findColumn(pinned, name); dup {
+			return nil, fmt.Errorf("column %s listed twice", name)
+		}
+		pinned = append(pinned, col)
+	}
+
+	for _, col := range columns {
+		if _, ok := findColumn(pinned, col.Name); !ok {
+			pinned = append(pinned, col)
+		}
+	}
+
+	return pinned, nil
+}
+
+func columnsOfKind(columns []column, kind string) []string {
+	var names []string
+	for _, col := range columns {
+		if col.Kind == kind {
+			names = append(names, col.Name)
+		}
+	}
+	return names
+}
+
+// ConnectionOptions holds the settings used to build the cluster config.
+// Zero values leave the corresponding gocql defaults untouched.
+type ConnectionOptions struct {
+	// Host and Port name the node to contact first.
+	Host string
+	Port int
+
+	// BindAddr is the local IP address outgoing connections bind to.
+	BindAddr string
+
+	// NumConns is the number of connections per host, and QueryRetries
+	// how often a failed schema query is retried.
+	NumConns     int
+	QueryRetries int
+
+	// Username and Password enable password authentication.
+	Username string
+	Password string
+
+	// AllowedAuthenticators is a comma-separated list of the server
+	// authenticator class names password authentication accepts. A
+	// non-empty list replaces gocql's defaults rather than extending them.
+	AllowedAuthenticators string
+
+	// TLS enables encrypted connections; setting any of the certificate
+	// paths implies it.
+	TLS           bool
+	TLSCA         string
+	TLSCert       string
+	TLSKey        string
+	TLSSkipVerify bool
+
+	// SerialConsistency is the consistency of the Paxos phase of
+	// conditional (IF) statements: serial or local_serial. Empty keeps
+	// gocql's default.
+	SerialConsistency string
+
+	// Timeout bounds the whole run rather than any single query.
+	Timeout time.Duration
+
+	// Cqlshrc is the cqlshrc file supplying defaults for unset flags, and
+	// Profile names the entry of the Config file that overrides them.
+	Cqlshrc string
+	Config  string
+	Profile string
+}
+
+// connectToScylla opens a session. A non-empty keyspace becomes the session
+// keyspace, which roles limited to one keyspace on locked-down clusters may
+// need before they can read system_schema.
+func connectToScylla(opts ConnectionOptions, keyspace string) (*gocql.Session, error) {
+	cluster, err := newClusterConfig(opts, keyspace)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.CreateSession()
+}
+
+// newClusterConfig turns the connection settings into a cluster
+// configuration, with keyspace as the session keyspace when it is not empty.
+func newClusterConfig(opts ConnectionOptions, keyspace string) (*gocql.ClusterConfig, error) {
+	cluster := gocql.NewCluster(opts.Host)
+	cluster.Port = opts.Port
+	cluster.Consistency = gocql.Quorum
+	cluster.Keyspace = keyspace
+
+	if opts.SerialConsistency != "" {
+		serial, err := parseSerialConsistency(opts.SerialConsistency)
+		if err != nil {
+			return nil, err
+		}
+		cluster.SerialConsistency = serial
+	}
+
+	if opts.NumConns > 0 {
+		cluster.NumConns = opts.NumConns
+	}
+
+	if opts.QueryRetries > 0 {
+		cluster.RetryPolicy = &gocql.SimpleRetryPolicy{NumRetries: opts.QueryRetries}
+	}
+
+	if opts.Username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username:              opts.Username,
+			Password:              opts.Password,
+			AllowedAuthenticators: splitList(opts.AllowedAuthenticators),
+		}
+	}
+
+	if opts.TLS || opts.TLSCA != "" || opts.TLSCert != "" || opts.TLSKey != "" {
+		cluster.SslOpts = &gocql.SslOptions{
+			CaPath:                 opts.TLSCA,
+			CertPath:               opts.TLSCert,
+			KeyPath:                opts.TLSKey,
+			EnableHostVerification: !opts.TLSSkipVerify,
+		}
+	}
+
+	if opts.BindAddr != "" {
+		ip := net.ParseIP(opts.BindAddr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid bind address %q: expected an IP address", opts.BindAddr)
+		}
+
+		cluster.Dialer = &net.Dialer{
+			LocalAddr: &net.TCPAddr{IP: ip},
+			Timeout:   cluster.ConnectTimeout,
+			KeepAlive: cluster.SocketKeepalive,
+		}
+	}
+
+	return cluster, nil
+}
+
+// parseSerialConsistency parses a -serialConsistency value, in any case.
+func parseSerialConsistency(value string) (gocql.SerialConsistency, error) {
+	switch strings.ToLower(value) {
+	case "serial":
+		return gocql.Serial, nil
+	case "local_serial":
+		return gocql.LocalSerial, nil
+	}
+	return 0, fmt.Errorf("invalid serial consistency %q: expected serial or local_serial", value)
+}
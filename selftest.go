@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
+)
+
+// selfTestScalars are the reflect types of the Go types cqlToGoType returns
+// for scalar columns.
+var selfTestScalars = map[string]reflect.Type{
+	"string":         reflect.TypeOf(""),
+	"bool":           reflect.TypeOf(false),
+	"byte":           reflect.TypeOf(byte(0)),
+	"int":            reflect.TypeOf(0),
+	"int8":           reflect.TypeOf(int8(0)),
+	"int16":          reflect.TypeOf(int16(0)),
+	"int32":          reflect.TypeOf(int32(0)),
+	"int64":          reflect.TypeOf(int64(0)),
+	"float32":        reflect.TypeOf(float32(0)),
+	"float64":        reflect.TypeOf(float64(0)),
+	"time.Time":      reflect.TypeOf(time.Time{}),
+	"time.Duration":  reflect.TypeOf(time.Duration(0)),
+	"net.IP":         reflect.TypeOf(net.IP{}),
+	"gocql.UUID":     reflect.TypeOf(gocql.UUID{}),
+	"gocql.Duration": reflect.TypeOf(gocql.Duration{}),
+	"big.Int":        reflect.TypeOf(big.Int{}),
+	"inf.Dec":        reflect.TypeOf(inf.Dec{}),
+}
+
+// selfTestTypes rebuilds generated Go types with reflect so real rows can be
+// scanned into them without compiling the generated code. A UDT becomes an
+// anonymous struct with the same cql tags as its generated struct.
+type selfTestTypes struct {
+	mapper typeMapper
+	udts   map[string]udtSchema
+}
+
+func newSelfTestTypes(schema keyspaceSchema, mapper typeMapper) selfTestTypes {
+	types := selfTestTypes{mapper: mapper, udts: make(map[string]udtSchema)}
+	for _, udt := range schema.Types {
+		types.udts[mapper.UDTs[udt.Name]] = udt
+	}
+	return types
+}
+
+func (t selfTestTypes) reflectType(goType string) (reflect.Type, error) {
+	if scalar, ok := selfTestScalars[goType]; ok {
+		return scalar, nil
+	}
+
+	switch {
+	case strings.HasPrefix(goType, "*"):
+		elem, err := t.reflectType(goType[1:])
+		if err != nil {
+			return nil, err
+		}
+		return reflect.PointerTo(elem), nil
+
+	case strings.HasPrefix(goType, "[]"):
+		elem, err := t.reflectType(goType[2:])
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(elem), nil
+
+	case strings.HasPrefix(goType, "map["):
+		// Key types nest brackets only when they are themselves maps, which
+		// Go does not allow, so the first ] closes the key.
+		keyType, valueType, ok := strings.Cut(goType[len("map["):], "]")
+		if !ok {
+			return nil, fmt.Errorf("malformed map type %s", goType)
+		}
+		key, err := t.reflectType(keyType)
+		if err != nil {
+			return nil, err
+		}
+		value, err := t.reflectType(valueType)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.MapOf(key, value), nil
+
+	case isOrderedMapType(goType):
+		return nil, fmt.Errorf("the generic %s cannot be rebuilt with reflect", goType)
+	}
+
+	udt, ok := t.udts[goType]
+	if !ok {
+		return nil, fmt.Errorf("no reflect type for %s", goType)
+	}
+
+	fields := make([]reflect.StructField, len(udt.Fields))
+	for i, field := range udt.Fields {
+		fieldType, err := t.mapper.cqlToGoType(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		reflected, err := t.reflectType(fieldType)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		fields[i] = reflect.StructField{
+			Name: fmt.Sprintf("Field%d", i),
+			Type: reflected,
+			Tag:  reflect.StructTag(fmt.Sprintf("cql:%q", field.Name)),
+		}
+	}
+	return reflect.StructOf(fields), nil
+}
+
+// runSelfTest reads a value of every column of each selected table, with
+// LIMIT 1 queries, and scans it into the Go type of the column's generated
+// struct field. It prints a line per column and returns the number of
+// columns that have no Go type or failed to scan.
+func runSelfTest(session *gocql.Session, schemas []keyspaceSchema, opts generateOptions) int {
+	failures := 0
+
+	for _, schema := range schemas {
+		mapper := opts.Struct.Types.withUserTypes(schema.Types, opts.typePrefix(schema.Name))
+		types := newSelfTestTypes(schema, mapper)
+
+		for _, table := range schema.Tables {
+			tableOpts := opts.Struct
+			tableOpts.Types = mapper
+			tableOpts.PointerColumns = opts.PointerColumns[table.Name]
+
+			fmt.Printf("Self-test of %s.%s\n", schema.Name, table.Name)
+
+			for _, col := range table.Columns {
+				goType, result, failed := selfTestColumn(session, schema.Name, table.Name, col, tableOpts, types)
+				if failed {
+					failures++
+				}
+				fmt.Printf("    %-24s %-24s %-24s %s\n", col.Name, col.Type, goType, result)
+			}
+		}
+	}
+
+	return failures
+}
+
+// selfTestColumn returns the Go field type of col, the outcome to print and
+// whether the column failed. Types reflect cannot rebuild are reported as not
+// checked rather than failed.
+func selfTestColumn(session *gocql.Session, keyspace string, tableName string, col column, opts structOptions, types selfTestTypes) (string, string, bool) {
+	goType, err := opts.fieldType(col)
+	if err != nil {
+		return "-", "unsupported: " + err.Error(), true
+	}
+	if strings.HasPrefix(goType, "sql.") {
+		return goType, "fails: gocql cannot scan into database/sql null types", true
+	}
+
+	reflected, err := types.reflectType(goType)
+	if err != nil {
+		return goType, "not checked: " + err.Error(), false
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s.%s LIMIT 1", cqlIdentifier(col.Name), cqlIdentifier(keyspace), cqlIdentifier(tableName))
+	err = session.Query(query).Scan(reflect.New(reflected).Interface())
+	if errors.Is(err, gocql.ErrNotFound) {
+		return goType, "not checked: the table has no rows", false
+	}
+	if err != nil {
+		return goType, "scan failed: " + err.Error(), true
+	}
+	return goType, "ok", false
+}
@@ -0,0 +1,3 @@
+module github.com/scylladb/gocqlx/v2
+
+go 1.22.5
@@ -0,0 +1,27 @@
+// Package qb mirrors the parts of gocqlx/v2/qb the generated helpers use.
+package qb
+
+// Order is the sort order of a clustering column.
+type Order bool
+
+const (
+	ASC  Order = true
+	DESC Order = false
+)
+
+// SelectBuilder builds SELECT statements.
+type SelectBuilder struct {
+	table   string
+	columns []string
+}
+
+// Select returns a builder selecting from table.
+func Select(table string) *SelectBuilder {
+	return &SelectBuilder{table: table}
+}
+
+// Columns adds the selected columns.
+func (b *SelectBuilder) Columns(columns ...string) *SelectBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
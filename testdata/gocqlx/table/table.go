@@ -0,0 +1,21 @@
+// Package table mirrors the parts of gocqlx/v2/table the generated helpers
+// use.
+package table
+
+// Metadata describes a table.
+type Metadata struct {
+	Name    string
+	Columns []string
+	PartKey []string
+	SortKey []string
+}
+
+// Table binds statements to a table's metadata.
+type Table struct {
+	metadata Metadata
+}
+
+// New returns a Table for m.
+func New(m Metadata) *Table {
+	return &Table{metadata: m}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// typeMapper translates CQL types into Go types.
+//
+// IntType selects how integer columns are mapped: "exact" matches the Go
+// type width to the CQL type except that int becomes the platform-sized Go
+// int, "strict" also maps int to int32, and "int64" maps tinyint, smallint,
+// int, bigint, counter and varint all to int64.
+//
+// UDTs maps user-defined type names, in their schema case, to their
+// generated structs.
+//
+// OrderedMap, when set, names the generated ordered map type that CQL maps
+// become in place of native Go maps.
+type typeMapper struct {
+	IntType    string
+	UDTs       map[string]string
+	OrderedMap string
+}
+
+// withUserTypes returns a copy of the mapper that resolves the given
+// user-defined types to their generated struct names, each starting with
+// prefix.
+func (m typeMapper) withUserTypes(types []udtSchema, prefix string) typeMapper {
+	m.UDTs = make(map[string]string, len(types))
+	for _, udt := range types {
+		m.UDTs[udt.Name] = prefix + toPascal(udt.Name)
+	}
+	if m.OrderedMap != "" {
+		m.OrderedMap = prefix + orderedMapTypeName
+	}
+	return m
+}
+
+// userTypeName resolves a type name to the user-defined type it refers to,
+// as the schema names it. Quoted names, as the server writes case-sensitive
+// ones, must match exactly; bare names match exactly first and then as CQL
+// folds them, in lowercase, so "MyType" and "mytype" can be distinct types of
+// one keyspace.
+func (m typeMapper) userTypeName(name string) (string, bool) {
+	if len(name) >= 2 && strings.HasPrefix(name, `"`) && strings.HasSuffix(name, `"`) {
+		name = strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+		_, ok := m.UDTs[name]
+		return name, ok
+	}
+	if _, ok := m.UDTs[name]; ok {
+		return name, true
+	}
+	name = strings.ToLower(name)
+	_, ok := m.UDTs[name]
+	return name, ok
+}
+
+// userType resolves a type name to its generated struct, as userTypeName
+// matches it.
+func (m typeMapper) userType(name string) (string, bool) {
+	udt, ok := m.userTypeName(name)
+	return m.UDTs[udt], ok
+}
+
+func validateIntType(intType string) error {
+	if intType != "exact" && intType != "strict" && intType != "int64" {
+		return fmt.Errorf("unknown int type %q: expected exact, strict or int64", intType)
+	}
+	return nil
+}
+
+// cqlToGoType returns the Go type for a CQL type. Scalars map to types gocql
+// marshals natively, so values round-trip: decimal is *inf.Dec, varint is
+// *big.Int, date is time.Time, time is time.Duration, duration is
+// gocql.Duration and inet is net.IP. Collections nest to any depth; sets of blobs or
+// collections become slices since Go map keys must be comparable. Native and
+// collection type names match in any case; user-defined type names keep
+// theirs.
+func (m typeMapper) cqlToGoType(cqlType string) (string, error) {
+	cqlType = strings.TrimSpace(cqlType)
+
+	if collection, params, ok := parseParameterizedType(cqlType); ok {
+		collection = strings.ToLower(collection)
+		switch {
+		case collection == "frozen" && len(params) == 1:
+			return m.cqlToGoType(params[0])
+
+		case collection == "map" && len(params) == 2:
+			goKeyType, err := m.cqlToGoType(params[0])
+			if err != nil {
+				return "", err
+			}
+			goValueType, err := m.cqlToGoType(params[1])
+			if err != nil {
+				return "", err
+			}
+
+			// The ordered map holds entries in a slice, so any key works.
+			if m.OrderedMap != "" {
+				return fmt.Sprintf("%s[%s, %s]", m.OrderedMap, goKeyType, goValueType), nil
+			}
+			if !isComparableGoType(goKeyType) {
+				return "", fmt.Errorf("unsupported CQL type %s: Go map keys cannot be %s", cqlType, goKeyType)
+			}
+
+			return fmt.Sprintf("map[%s]%s", goKeyType, goValueType), nil
+
+		case collection == "list" && len(params) == 1:
+			goElemType, err := m.cqlToGoType(params[0])
+			if err != nil {
+				return "", err
+			}
+
+			return fmt.Sprintf("[]%s", goElemType), nil
+
+		case collection == "set" && len(params) == 1:
+			goElemType, err := m.cqlToGoType(params[0])
+			if err != nil {
+				return "", err
+			}
+
+			// gocql unmarshals sets only into slices and arrays.
+			return fmt.Sprintf("[]%s", goElemType), nil
+		}
+
+		return "", fmt.Errorf("unknown CQL type: %s", cqlType)
+	}
+
+	if structName, ok := m.userType(cqlType); ok {
+		return structName, nil
+	}
+
+	nativeType := strings.ToLower(cqlType)
+	if m.IntType == "int64" {
+		switch nativeType {
+		case "tinyint", "smallint", "int", "bigint", "counter", "varint":
+			return "int64", nil
+		}
+	}
+
+	switch nativeType {
+	case "uuid", "timeuuid":
+		return "gocql.UUID", nil
+	case "boolean":
+		return "bool", nil
+	case "text", "varchar", "ascii":
+		return "string", nil
+	case "inet":
+		return "net.IP", nil
+	case "int":
+		if m.IntType == "strict" {
+			return "int32", nil
+		}
+		return "int", nil
+	case "bigint", "counter":
+		return "int64", nil
+	case "varint":
+		return "*big.Int", nil
+	case "tinyint":
+		return "int8", nil
+	case "smallint":
+		return "int16", nil
+	case "float":
+		return "float32", nil
+	case "double":
+		return "float64", nil
+	case "decimal":
+		return "*inf.Dec", nil
+	case "timestamp":
+		return "time.Time", nil
+	case "date":
+		return "time.Time", nil
+	case "time":
+		return "time.Duration", nil
+	case "blob":
+		return "[]byte", nil
+	case "duration":
+		// gocql.Duration (months, days, nanoseconds) is available from
+		// gocql v1.0 onwards, which go.mod already requires.
+		return "gocql.Duration", nil
+	default:
+		return "", fmt.Errorf("unknown CQL type: %s", cqlType)
+	}
+}
+
+// isComparableGoType reports whether a generated Go type can be a map key.
+// Slices, including net.IP, maps and the ordered map cannot; every other
+// generated type, including the UDT structs, is treated as comparable.
+func isComparableGoType(goType string) bool {
+	return !strings.HasPrefix(goType, "[]") && goType != "net.IP" && !strings.HasPrefix(goType, "map[") && !isOrderedMapType(goType)
+}
+
+// parseParameterizedType splits a type such as "map<text, frozen<list<int>>>"
+// into its name and top-level parameters, honouring nested angle brackets so
+// commas inside inner types are not treated as separators. The name and each
+// parameter are trimmed, so the spacing differences between server versions
+// (map<text, text> from Cassandra, map<text,text> elsewhere) do not matter.
+func parseParameterizedType(cqlType string) (string, []string, bool) {
+	open := strings.IndexByte(cqlType, '<')
+	if open <= 0 || !strings.HasSuffix(cqlType, ">") {
+		return "", nil, false
+	}
+
+	name := strings.TrimSpace(cqlType[:open])
+	inner := cqlType[open+1 : len(cqlType)-1]
+
+	var params []string
+	depth, start := 0, 0
+	for i, r := range inner {
+		switch r {
+		case '<':
+			depth++
+		case '>':
+			depth--
+			if depth < 0 {
+				return "", nil, false
+			}
+		case ',':
+			if depth == 0 {
+				params = append(params, strings.TrimSpace(inner[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	if depth != 0 {
+		return "", nil, false
+	}
+
+	params = append(params, strings.TrimSpace(inner[start:]))
+	for _, param := range params {
+		if param == "" {
+			return "", nil, false
+		}
+	}
+
+	return name, params, true
+}
+
+// canonicalCQLType respells a type string the same way whichever server
+// wrote it: collection and native type names in lowercase, parameters
+// separated by ", " and user-defined type names as the schema names them,
+// quoted when CQL needs it. frozen is kept, unlike in parseCQLType, since it
+// is part of the schema.
+func (m typeMapper) canonicalCQLType(cqlType string) string {
+	cqlType = strings.TrimSpace(cqlType)
+
+	if name, params, ok := parseParameterizedType(cqlType); ok {
+		for i, param := range params {
+			params[i] = m.canonicalCQLType(param)
+		}
+		return strings.ToLower(name) + "<" + strings.Join(params, ", ") + ">"
+	}
+
+	if udt, ok := m.userTypeName(cqlType); ok {
+		return cqlIdentifier(udt)
+	}
+	return strings.ToLower(cqlType)
+}
+
+// cqlTypeNode is a parsed CQL type: a lowercased name, such as "map" or
+// "text", with its type parameters. frozen<T> is unwrapped to T. A
+// user-defined type has its name as the schema spells it in both Name and
+// UDT; UDT is empty for every other type. Emitters for non-Go formats walk
+// this tree instead of re-parsing type strings.
+type cqlTypeNode struct {
+	Name   string
+	UDT    string
+	Params []cqlTypeNode
+}
+
+// parseCQLType parses a type string, resolving user-defined type names the
+// way cqlToGoType does.
+func (m typeMapper) parseCQLType(cqlType string) (cqlTypeNode, error) {
+	cqlType = strings.TrimSpace(cqlType)
+
+	name, params, ok := parseParameterizedType(cqlType)
+	if !ok {
+		if strings.ContainsAny(cqlType, "<>,") || cqlType == "" {
+			return cqlTypeNode{}, fmt.Errorf("unknown CQL type: %s", cqlType)
+		}
+		if udt, ok := m.userTypeName(cqlType); ok {
+			return cqlTypeNode{Name: udt, UDT: udt}, nil
+		}
+		return cqlTypeNode{Name: strings.ToLower(cqlType)}, nil
+	}
+
+	node := cqlTypeNode{Name: strings.ToLower(name)}
+	for _, param := range params {
+		child, err := m.parseCQLType(param)
+		if err != nil {
+			return cqlTypeNode{}, err
+		}
+		node.Params = append(node.Params, child)
+	}
+
+	if node.Name == "frozen" && len(node.Params) == 1 {
+		return node.Params[0], nil
+	}
+	return node, nil
+}
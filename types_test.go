@@ -0,0 +1,547 @@
+package main
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"gopkg.in/inf.v0"
+)
+
+func TestCQLToGoTypeScalars(t *testing.T) {
+	tests := map[string]string{
+		"ascii":     "string",
+		"bigint":    "int64",
+		"blob":      "[]byte",
+		"boolean":   "bool",
+		"counter":   "int64",
+		"date":      "time.Time",
+		"decimal":   "*inf.Dec",
+		"double":    "float64",
+		"duration":  "gocql.Duration",
+		"float":     "float32",
+		"inet":      "net.IP",
+		"int":       "int",
+		"smallint":  "int16",
+		"text":      "string",
+		"time":      "time.Duration",
+		"timestamp": "time.Time",
+		"timeuuid":  "gocql.UUID",
+		"tinyint":   "int8",
+		"uuid":      "gocql.UUID",
+		"varchar":   "string",
+		"varint":    "*big.Int",
+	}
+
+	mapper := typeMapper{IntType: "exact"}
+	for cqlType, want := range tests {
+		got, err := mapper.cqlToGoType(cqlType)
+		if err != nil {
+			t.Errorf("cqlToGoType(%q): %v", cqlType, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("cqlToGoType(%q) = %s, want %s", cqlType, got, want)
+		}
+	}
+}
+
+func TestCQLToGoTypeInetCollections(t *testing.T) {
+	mapper := typeMapper{IntType: "exact"}
+
+	// net.IP is a slice, so it cannot be a map key.
+	got, err := mapper.cqlToGoType("set<inet>")
+	if err != nil || got != "[]net.IP" {
+		t.Errorf("cqlToGoType(set<inet>) = %q, %v; want []net.IP", got, err)
+	}
+	if _, err := mapper.cqlToGoType("map<inet, text>"); err == nil || !strings.Contains(err.Error(), "Go map keys") {
+		t.Errorf("cqlToGoType(map<inet, text>) error = %v, want a map key error", err)
+	}
+}
+
+func TestUserTypeCase(t *testing.T) {
+	mapper := typeMapper{IntType: "exact"}.withUserTypes([]udtSchema{{Name: "MyType"}, {Name: "mytype"}}, "")
+
+	tests := []struct {
+		cqlType string
+		want    string
+	}{
+		{`frozen<"MyType">`, "MyType"},
+		{`"MyType"`, "MyType"},
+		{"MyType", "MyType"},
+		{"frozen<mytype>", "Mytype"},
+		{`"mytype"`, "Mytype"},
+		{"MYTYPE", "Mytype"},
+		{`LIST<FROZEN<"MyType">>`, "[]MyType"},
+		{`map<TEXT, frozen<"MyType">>`, "map[string]MyType"},
+	}
+	for _, tt := range tests {
+		got, err := mapper.cqlToGoType(tt.cqlType)
+		if err != nil {
+			t.Errorf("cqlToGoType(%q): %v", tt.cqlType, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("cqlToGoType(%q) = %s, want %s", tt.cqlType, got, tt.want)
+		}
+
+		node, err := mapper.parseCQLType(tt.cqlType)
+		if err != nil {
+			t.Errorf("parseCQLType(%q): %v", tt.cqlType, err)
+		}
+		for len(node.Params) > 0 {
+			node = node.Params[len(node.Params)-1]
+		}
+		if structName := mapper.UDTs[node.UDT]; node.UDT == "" || !strings.HasSuffix(tt.want, structName) {
+			t.Errorf("parseCQLType(%q) resolved the type to %q, want the struct of %s", tt.cqlType, node.UDT, tt.want)
+		}
+	}
+
+	// A quoted name must match exactly.
+	if _, err := mapper.cqlToGoType(`"MYTYPE"`); err == nil {
+		t.Error(`cqlToGoType("MYTYPE") resolved a quoted name in another case`)
+	}
+}
+
+func TestMixedCaseUserTypeInEveryFormat(t *testing.T) {
+	schemas := []keyspaceSchema{{
+		Name:  "app",
+		Types: []udtSchema{{Name: "MyType", Fields: []udtField{{Name: "street", Type: "text"}}}},
+		Tables: []tableSchema{{
+			Name: "users",
+			Columns: []column{
+				{Name: "id", Type: "uuid", Kind: "partition_key"},
+				{Name: "home", Type: `frozen<"MyType">`, Kind: "regular"},
+				{Name: "others", Type: `list<frozen<"MyType">>`, Kind: "regular"},
+			},
+		}},
+	}}
+
+	for _, format := range formatNames {
+		t.Run(format, func(t *testing.T) {
+			files, err := formatEmitters[format](schemas, testGenerateOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format == "mapscan" || format == "describe" || format == "schema-json" {
+				return
+			}
+			for _, file := range files {
+				if !strings.Contains(string(file.Content), "MyType") {
+					t.Errorf("%s does not refer to MyType:\n%s", file.Path, file.Content)
+				}
+			}
+		})
+	}
+}
+
+func TestCQLToGoTypeNestedCollections(t *testing.T) {
+	tests := []struct {
+		cqlType string
+		want    string
+	}{
+		{"list<list<int>>", "[][]int"},
+		{"list<frozen<list<int>>>", "[][]int"},
+		{"set<frozen<list<int>>>", "[][]int"},
+		{"set<frozen<set<text>>>", "[][]string"},
+		{"list<frozen<set<int>>>", "[][]int"},
+		{"map<text, frozen<list<int>>>", "map[string][]int"},
+		{"list<frozen<list<frozen<list<int>>>>>", "[][][]int"},
+		{"list<list<list<text>>>", "[][][]string"},
+		{"set<frozen<list<frozen<map<text, int>>>>>", "[][]map[string]int"},
+		{"map<int, frozen<map<text, frozen<list<bigint>>>>>", "map[int]map[string][]int64"},
+	}
+
+	mapper := typeMapper{IntType: "exact"}
+	for _, tt := range tests {
+		got, err := mapper.cqlToGoType(tt.cqlType)
+		if err != nil {
+			t.Errorf("cqlToGoType(%q): %v", tt.cqlType, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("cqlToGoType(%q) = %s, want %s", tt.cqlType, got, tt.want)
+		}
+	}
+}
+
+func TestCQLToGoTypeCollectionMapKeys(t *testing.T) {
+	mapper := typeMapper{IntType: "exact"}
+
+	for _, cqlType := range []string{
+		"map<frozen<list<int>>, text>",
+		"map<frozen<set<text>>, int>",
+		"map<frozen<map<text, int>>, text>",
+		"map<blob, text>",
+	} {
+		_, err := mapper.cqlToGoType(cqlType)
+		if err == nil || !strings.Contains(err.Error(), "Go map keys") {
+			t.Errorf("cqlToGoType(%q) error = %v, want a map key error", cqlType, err)
+		}
+	}
+
+	// The ordered map keeps entries in a slice, so any key type works.
+	ordered := typeMapper{IntType: "exact", OrderedMap: orderedMapTypeName}
+	if got, err := ordered.cqlToGoType("map<frozen<list<int>>, text>"); err != nil || got != "OrderedMap[[]int, string]" {
+		t.Errorf("ordered cqlToGoType(map<frozen<list<int>>, text>) = %q, %v; want OrderedMap[[]int, string]", got, err)
+	}
+}
+
+func TestCQLToGoTypeMalformed(t *testing.T) {
+	mapper := typeMapper{IntType: "exact"}
+	for _, cqlType := range []string{"list<int", "list<>", "map<text>", "map<text, int, int>", "list<int>>", "set<text, text>", "frozen<list<int>"} {
+		if got, err := mapper.cqlToGoType(cqlType); err == nil {
+			t.Errorf("cqlToGoType(%q) = %s, want an error", cqlType, got)
+		}
+	}
+}
+
+func TestExoticScalarsRoundTripThroughGocql(t *testing.T) {
+	tests := []struct {
+		cqlType  string
+		typeCode gocql.Type
+		goType   string
+		value    interface{}
+	}{
+		{"decimal", gocql.TypeDecimal, "*inf.Dec", inf.NewDec(-123456789, 4)},
+		{"varint", gocql.TypeVarint, "*big.Int", new(big.Int).Lsh(big.NewInt(-7), 100)},
+		{"duration", gocql.TypeDuration, "gocql.Duration", gocql.Duration{Months: 14, Days: -3, Nanoseconds: 5 * int64(time.Hour)}},
+		{"time", gocql.TypeTime, "time.Duration", 13*time.Hour + 4*time.Minute + 5*time.Nanosecond},
+		{"date", gocql.TypeDate, "time.Time", time.Date(1969, time.July, 20, 0, 0, 0, 0, time.UTC)},
+	}
+
+	mapper := typeMapper{IntType: "exact"}
+	for _, tt := range tests {
+		t.Run(tt.cqlType, func(t *testing.T) {
+			goType, err := mapper.cqlToGoType(tt.cqlType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if goType != tt.goType {
+				t.Fatalf("cqlToGoType(%q) = %s, want %s", tt.cqlType, goType, tt.goType)
+			}
+
+			// The reflect type the self-test rebuilds for the field must be
+			// the type of the value.
+			reflected, err := selfTestTypes{mapper: mapper}.reflectType(goType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reflected != reflect.TypeOf(tt.value) {
+				t.Fatalf("%s is %v, the value is %T", goType, reflected, tt.value)
+			}
+
+			info := gocql.NewNativeType(routingProtoVersion, tt.typeCode, "")
+			data, err := gocql.Marshal(info, tt.value)
+			if err != nil {
+				t.Fatalf("marshaling %v: %v", tt.value, err)
+			}
+
+			target := reflect.New(reflected)
+			if err := gocql.Unmarshal(info, data, target.Interface()); err != nil {
+				t.Fatalf("unmarshaling %v: %v", tt.value, err)
+			}
+
+			got := target.Elem().Interface()
+			switch want := tt.value.(type) {
+			case *inf.Dec:
+				if got.(*inf.Dec).Cmp(want) != 0 {
+					t.Errorf("round trip gave %v, want %v", got, want)
+				}
+			case *big.Int:
+				if got.(*big.Int).Cmp(want) != 0 {
+					t.Errorf("round trip gave %v, want %v", got, want)
+				}
+			case time.Time:
+				if !got.(time.Time).Equal(want) {
+					t.Errorf("round trip gave %v, want %v", got, want)
+				}
+			default:
+				if got != want {
+					t.Errorf("round trip gave %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSetsRoundTripThroughGocql(t *testing.T) {
+	mapper := typeMapper{IntType: "exact"}
+	goType, err := mapper.cqlToGoType("set<text>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reflected, err := selfTestTypes{mapper: mapper}.reflectType(goType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := gocql.CollectionType{
+		NativeType: gocql.NewNativeType(routingProtoVersion, gocql.TypeSet, ""),
+		Elem:       gocql.NewNativeType(routingProtoVersion, gocql.TypeText, ""),
+	}
+	tags := []string{"new", "sale"}
+	data, err := gocql.Marshal(info, tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Scan into the type the generated struct declares, as -selfTest does.
+	target := reflect.New(reflected)
+	if err := gocql.Unmarshal(info, data, target.Interface()); err != nil {
+		t.Fatalf("unmarshaling set<text> into %s: %v", goType, err)
+	}
+	if got := target.Elem().Interface(); !reflect.DeepEqual(got, tags) {
+		t.Errorf("round trip gave %v, want %v", got, tags)
+	}
+}
+
+func TestCQLToGoTypeIntModes(t *testing.T) {
+	cqlTypes := []string{"tinyint", "smallint", "int", "bigint", "counter", "varint", "list<int>", "map<int, bigint>"}
+	tests := map[string][]string{
+		"exact":  {"int8", "int16", "int", "int64", "int64", "*big.Int", "[]int", "map[int]int64"},
+		"strict": {"int8", "int16", "int32", "int64", "int64", "*big.Int", "[]int32", "map[int32]int64"},
+		"int64":  {"int64", "int64", "int64", "int64", "int64", "int64", "[]int64", "map[int64]int64"},
+	}
+
+	for intType, want := range tests {
+		mapper := typeMapper{IntType: intType}
+		for i, cqlType := range cqlTypes {
+			got, err := mapper.cqlToGoType(cqlType)
+			if err != nil {
+				t.Errorf("%s: cqlToGoType(%q): %v", intType, cqlType, err)
+				continue
+			}
+			if got != want[i] {
+				t.Errorf("%s: cqlToGoType(%q) = %s, want %s", intType, cqlType, got, want[i])
+			}
+		}
+	}
+}
+
+func TestIntTypeOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		want    string
+		invalid bool
+	}{
+		{name: "default", want: "exact"},
+		{name: "strictInt", opts: Options{StrictInt: true}, want: "strict"},
+		{name: "intType strict", opts: Options{IntType: "strict"}, want: "strict"},
+		{name: "intType int64", opts: Options{IntType: "int64"}, want: "int64"},
+		{name: "strictInt and intType strict", opts: Options{StrictInt: true, IntType: "strict"}, want: "strict"},
+		{name: "strictInt and intType int64", opts: Options{StrictInt: true, IntType: "int64"}, invalid: true},
+		{name: "unknown", opts: Options{IntType: "int32"}, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Schema = strings.NewReader("{}")
+			opts := tt.opts.withDefaults()
+			err := opts.Validate()
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("Validate accepted IntType %q with StrictInt %v", opts.IntType, opts.StrictInt)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			genOpts, err := opts.generateOptions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := genOpts.Struct.Types.IntType; got != tt.want {
+				t.Errorf("IntType = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// serverTypeSpellings pairs type strings as Cassandra 4.x writes them to
+// system_schema.columns, with ", " between parameters, with the spelling
+// without spaces reported from Scylla clusters.
+var serverTypeSpellings = []struct {
+	cassandra string
+	scylla    string
+	goType    string
+}{
+	{"map<text, text>", "map<text,text>", "map[string]string"},
+	{"frozen<map<text, int>>", "frozen<map<text,int>>", "map[string]int"},
+	{"map<text, frozen<list<int>>>", "map<text,frozen<list<int>>>", "map[string][]int"},
+	{"map<int, frozen<map<text, bigint>>>", "map<int,frozen<map<text,bigint>>>", "map[int]map[string]int64"},
+	{"set<frozen<map<text, boolean>>>", "set<frozen<map<text,boolean>>>", "[]map[string]bool"},
+	{"list<frozen<map<timeuuid, double>>>", "list<frozen<map<timeuuid,double>>>", "[]map[gocql.UUID]float64"},
+}
+
+func TestParseParameterizedTypeServerSpellings(t *testing.T) {
+	for _, spelling := range serverTypeSpellings {
+		cassandraName, cassandraParams, ok := parseParameterizedType(spelling.cassandra)
+		if !ok {
+			t.Errorf("parseParameterizedType(%q) failed", spelling.cassandra)
+			continue
+		}
+		scyllaName, scyllaParams, ok := parseParameterizedType(spelling.scylla)
+		if !ok {
+			t.Errorf("parseParameterizedType(%q) failed", spelling.scylla)
+			continue
+		}
+
+		if cassandraName != scyllaName || len(cassandraParams) != len(scyllaParams) {
+			t.Errorf("%q parses as %s%q, %q as %s%q", spelling.cassandra, cassandraName, cassandraParams, spelling.scylla, scyllaName, scyllaParams)
+			continue
+		}
+		for i, param := range cassandraParams {
+			if param != strings.TrimSpace(param) {
+				t.Errorf("parameter %q of %q is not trimmed", param, spelling.cassandra)
+			}
+			if strings.ReplaceAll(param, " ", "") != scyllaParams[i] {
+				t.Errorf("parameter %d of %q is %q, of %q it is %q", i, spelling.cassandra, param, spelling.scylla, scyllaParams[i])
+			}
+		}
+	}
+}
+
+func TestCQLToGoTypeServerSpellings(t *testing.T) {
+	mapper := typeMapper{IntType: "exact"}
+	for _, spelling := range serverTypeSpellings {
+		for _, cqlType := range []string{spelling.cassandra, spelling.scylla, " " + spelling.cassandra + " ", strings.ReplaceAll(spelling.cassandra, ", ", " ,  ")} {
+			got, err := mapper.cqlToGoType(cqlType)
+			if err != nil {
+				t.Errorf("cqlToGoType(%q): %v", cqlType, err)
+				continue
+			}
+			if got != spelling.goType {
+				t.Errorf("cqlToGoType(%q) = %s, want %s", cqlType, got, spelling.goType)
+			}
+		}
+	}
+}
+
+func TestParseParameterizedTypeNestedCommas(t *testing.T) {
+	tests := []struct {
+		cqlType string
+		name    string
+		params  []string
+		goType  string
+		keyErr  bool
+	}{
+		{"map<text, map<int,text>>", "map", []string{"text", "map<int,text>"}, "map[string]map[int]string", false},
+		{"list<map<text,int>>", "list", []string{"map<text,int>"}, "[]map[string]int", false},
+		{"map<frozen<list<int>>, text>", "map", []string{"frozen<list<int>>", "text"}, "", true},
+		{"map<frozen<map<int, text>>, frozen<map<text, int>>>", "map", []string{"frozen<map<int, text>>", "frozen<map<text, int>>"}, "", true},
+	}
+
+	mapper := typeMapper{IntType: "exact"}
+	for _, tt := range tests {
+		name, params, ok := parseParameterizedType(tt.cqlType)
+		if !ok || name != tt.name || strings.Join(params, "|") != strings.Join(tt.params, "|") {
+			t.Errorf("parseParameterizedType(%q) = %s, %q, %v; want %s, %q", tt.cqlType, name, params, ok, tt.name, tt.params)
+		}
+
+		goType, err := mapper.cqlToGoType(tt.cqlType)
+		if tt.keyErr {
+			if err == nil || !strings.Contains(err.Error(), "Go map keys") {
+				t.Errorf("cqlToGoType(%q) = %q, %v; want a map key error", tt.cqlType, goType, err)
+			}
+			continue
+		}
+		if err != nil || goType != tt.goType {
+			t.Errorf("cqlToGoType(%q) = %q, %v; want %s", tt.cqlType, goType, err, tt.goType)
+		}
+	}
+}
+
+// testAddress mirrors the struct generateUDTStruct emits for the address
+// type in TestUDTSliceRoundTripsThroughGocql.
+type testAddress struct {
+	Street string `cql:"street" json:"street"`
+	Zip    int    `cql:"zip" json:"zip"`
+}
+
+func TestUDTSliceRoundTripsThroughGocql(t *testing.T) {
+	udt := udtSchema{Name: "address", Fields: []udtField{
+		{Name: "street", Type: "text"},
+		{Name: "zip", Type: "int"},
+	}}
+	mapper := typeMapper{IntType: "exact"}.withUserTypes([]udtSchema{udt}, "")
+
+	source, err := generateUDTStruct(udt, structOptions{Types: mapper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "type Address struct {\n    Street string `cql:\"street\" json:\"street\"`\n    Zip int `cql:\"zip\" json:\"zip\"`\n}\n"
+	if source != want {
+		t.Fatalf("generateUDTStruct gave\n%s\nwant\n%s", source, want)
+	}
+
+	goType, err := mapper.cqlToGoType("list<frozen<address>>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if goType != "[]Address" {
+		t.Fatalf("list<frozen<address>> is %s, want []Address", goType)
+	}
+
+	info := gocql.CollectionType{
+		NativeType: gocql.NewNativeType(routingProtoVersion, gocql.TypeList, ""),
+		Elem: gocql.UDTTypeInfo{
+			NativeType: gocql.NewNativeType(routingProtoVersion, gocql.TypeUDT, ""),
+			KeySpace:   "app",
+			Name:       "address",
+			Elements: []gocql.UDTField{
+				{Name: "street", Type: gocql.NewNativeType(routingProtoVersion, gocql.TypeText, "")},
+				{Name: "zip", Type: gocql.NewNativeType(routingProtoVersion, gocql.TypeInt, "")},
+			},
+		},
+	}
+
+	addresses := []testAddress{{Street: "1 Main St", Zip: 12345}, {Street: "2 High St", Zip: 0}}
+	data, err := gocql.Marshal(info, addresses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []testAddress
+	if err := gocql.Unmarshal(info, data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, addresses) {
+		t.Errorf("round trip gave %+v, want %+v", got, addresses)
+	}
+}
+
+func TestCanonicalCQLType(t *testing.T) {
+	mapper := typeMapper{}.withUserTypes([]udtSchema{{Name: "address"}, {Name: "HomeAddress"}}, "")
+
+	tests := []struct {
+		cqlType string
+		want    string
+	}{
+		{"TEXT", "text"},
+		{" int ", "int"},
+		{"address", "address"},
+		{`"HomeAddress"`, `"HomeAddress"`},
+		{"frozen<address>", "frozen<address>"},
+		{`list<frozen<"HomeAddress">>`, `list<frozen<"HomeAddress">>`},
+		{"Map<Text,Frozen<List<Int>>>", "map<text, frozen<list<int>>>"},
+	}
+	for _, spelling := range serverTypeSpellings {
+		tests = append(tests, struct {
+			cqlType string
+			want    string
+		}{spelling.scylla, spelling.cassandra})
+	}
+
+	for _, tt := range tests {
+		if got := mapper.canonicalCQLType(tt.cqlType); got != tt.want {
+			t.Errorf("canonicalCQLType(%q) = %q, want %q", tt.cqlType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// emitTypeScript writes one module per keyspace with an interface for every
+// user-defined type and table. Primary key properties are required; all other
+// properties are optional because CQL columns outside the key may be null.
+func emitTypeScript(schemas []keyspaceSchema, opts generateOptions) ([]generatedFile, error) {
+	var files []generatedFile
+
+	for _, schema := range schemas {
+		mapper := typeMapper{}.withUserTypes(schema.Types, "")
+
+		source := opts.Header + generatedHeader + "\n" + partialNote(opts)
+
+		for _, udt := range schema.Types {
+			source += fmt.Sprintf("\nexport interface %s {\n", toPascal(udt.Name))
+			for _, field := range udt.Fields {
+				tsType, err := cqlToTypeScript(field.Type, mapper)
+				if err != nil {
+					return nil, fmt.Errorf("type %s field %s: %w", udt.Name, field.Name, err)
+				}
+				source += fmt.Sprintf("  %s?: %s;\n", tsPropertyName(field.Name), tsType)
+			}
+			source += "}\n"
+		}
+
+		for _, table := range schema.Tables {
+			source += fmt.Sprintf("\nexport interface %s {\n", toPascal(table.Name))
+			for _, col := range table.Columns {
+				tsType, err := cqlToTypeScript(col.Type, mapper)
+				if err != nil {
+					return nil, fmt.Errorf("table %s column %s: %w", table.Name, col.Name, err)
+				}
+
+				optional := "?"
+				if isPrimaryKey(col) {
+					optional = ""
+				}
+				source += fmt.Sprintf("  %s%s: %s;\n", tsPropertyName(col.Name), optional, tsType)
+			}
+			source += "}\n"
+		}
+
+		files = append(files, generatedFile{Path: sanitizePathComponent(schema.Name) + "/models.ts", Content: []byte(source)})
+	}
+
+	return files, nil
+}
+
+func tsPropertyName(name string) string {
+	for i, r := range name {
+		isLetter := r == '_' || r == '$' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if !isLetter && (i == 0 || r < '0' || r > '9') {
+			return fmt.Sprintf("%q", name)
+		}
+	}
+	return name
+}
+
+// cqlToTypeScript maps a CQL type to the TypeScript type of its JSON form.
+// Sets are modelled as arrays, matching their CQL semantics.
+func cqlToTypeScript(cqlType string, mapper typeMapper) (string, error) {
+	node, err := mapper.parseCQLType(cqlType)
+	if err != nil {
+		return "", err
+	}
+	return typeScriptType(node, mapper)
+}
+
+func typeScriptType(node cqlTypeNode, mapper typeMapper) (string, error) {
+	switch {
+	case (node.Name == "list" || node.Name == "set") && len(node.Params) == 1:
+		elem, err := typeScriptType(node.Params[0], mapper)
+		if err != nil {
+			return "", err
+		}
+		if strings.ContainsAny(elem, " |") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]", nil
+
+	case node.Name == "map" && len(node.Params) == 2:
+		value, err := typeScriptType(node.Params[1], mapper)
+		if err != nil {
+			return "", err
+		}
+		return "Record<string, " + value + ">", nil
+
+	case len(node.Params) > 0:
+		return "", fmt.Errorf("unknown CQL type: %s", node.Name)
+	}
+
+	if node.UDT != "" {
+		return mapper.UDTs[node.UDT], nil
+	}
+
+	switch node.Name {
+	case "boolean":
+		return "boolean", nil
+	case "tinyint", "smallint", "int", "bigint", "counter", "float", "double", "time":
+		return "number", nil
+	case "text", "varchar", "ascii", "uuid", "timeuuid", "inet", "timestamp", "date", "blob", "decimal", "varint", "duration":
+		return "string", nil
+	default:
+		return "", fmt.Errorf("unknown CQL type: %s", node.Name)
+	}
+}